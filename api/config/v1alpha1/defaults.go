@@ -0,0 +1,76 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// DefaultRedactEnvNames matches the names of environment variables that
+// commonly hold credentials.
+const DefaultRedactEnvNames = `(?i)(pass(word|wd)?|secret|token|api[-_]?key|credential|private[-_]?key)`
+
+// Default returns the configuration used where neither the configuration
+// file nor a flag sets a field. The file is decoded onto it, so that fields
+// explicitly set to their zero value in the file are kept.
+func Default() *WebhookServerConfiguration {
+	return &WebhookServerConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupVersion.String(),
+			Kind:       WebhookServerConfigurationKind,
+		},
+		Webhook: WebhookConfiguration{
+			Port:           9443,
+			CertDir:        "/tmp/k8s-webhook-server/serving-certs",
+			ServiceAccount: "mutato",
+			NotReadyPolicy: mutationsv1alpha1.FailurePolicyFail,
+		},
+		Metrics: MetricsConfiguration{
+			BindAddress: ":8080",
+		},
+		Health: HealthConfiguration{
+			ProbeBindAddress: ":8081",
+		},
+		LeaderElection: LeaderElectionConfiguration{
+			ResourceName: "mutato.kubesphere.io",
+		},
+		Mutation: MutationConfiguration{
+			AuditAnnotations: true,
+		},
+		Bundles: BundlesConfiguration{
+			Dir:          "/var/run/mutato/bundles",
+			PollInterval: metav1.Duration{Duration: 30 * time.Second},
+		},
+		DecisionLog: DecisionLogConfiguration{
+			File:           "/var/log/mutato/decisions.log",
+			FileMaxSizeMiB: 100,
+			FileMaxBackups: 3,
+			BufferSize:     10000,
+			BatchSize:      100,
+			FlushInterval:  metav1.Duration{Duration: 5 * time.Second},
+		},
+		Redaction: RedactionConfiguration{
+			EnvNames: DefaultRedactEnvNames,
+		},
+		Tracing: TracingConfiguration{
+			SampleRatio: 0.1,
+		},
+	}
+}
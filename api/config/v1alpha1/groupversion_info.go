@@ -0,0 +1,28 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 contains the configuration file format of the webhook
+// server, in the config v1alpha1 API group
+// +kubebuilder:object:generate=true
+// +groupName=config.mutato.kubesphere.io
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersion is group version of the configuration file.
+var GroupVersion = schema.GroupVersion{Group: "config.mutato.kubesphere.io", Version: "v1alpha1"}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// WebhookServerConfigurationKind is the kind of the configuration file.
+const WebhookServerConfigurationKind = "WebhookServerConfiguration"
+
+// +kubebuilder:object:root=true
+
+// WebhookServerConfiguration configures mutato-webhook-server. Fields left
+// out of the file keep their defaults, and flags given on the command line
+// override the file.
+type WebhookServerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Webhook        WebhookConfiguration        `json:"webhook,omitempty"`
+	Metrics        MetricsConfiguration        `json:"metrics,omitempty"`
+	Health         HealthConfiguration         `json:"health,omitempty"`
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+	Mutation       MutationConfiguration       `json:"mutation,omitempty"`
+	Bundles        BundlesConfiguration        `json:"bundles,omitempty"`
+	DecisionLog    DecisionLogConfiguration    `json:"decisionLog,omitempty"`
+	Redaction      RedactionConfiguration      `json:"redaction,omitempty"`
+	Tracing        TracingConfiguration        `json:"tracing,omitempty"`
+	Debug          DebugConfiguration          `json:"debug,omitempty"`
+}
+
+// WebhookConfiguration configures the webhook server.
+type WebhookConfiguration struct {
+	// Port the webhook server listens on.
+	Port int `json:"port,omitempty"`
+	// CertDir contains the serving certificate, tls.crt and tls.key.
+	CertDir string `json:"certDir,omitempty"`
+	// ServiceAccount is the service account of Mutato in its namespace.
+	// Requests of this service account are never mutated, so that Mutato
+	// does not mutate the objects it writes itself.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// NotReadyPolicy handles admission requests until the mutators existing
+	// at startup are ingested: Fail fails them, Ignore admits them
+	// unmutated.
+	NotReadyPolicy mutationsv1alpha1.FailurePolicy `json:"notReadyPolicy,omitempty"`
+}
+
+// MetricsConfiguration configures the Prometheus metrics endpoint.
+type MetricsConfiguration struct {
+	// BindAddress the metrics endpoint binds to. "0" disables it.
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// HealthConfiguration configures the health probe endpoints.
+type HealthConfiguration struct {
+	// ProbeBindAddress the /healthz and /readyz endpoints bind to.
+	ProbeBindAddress string `json:"probeBindAddress,omitempty"`
+}
+
+// LeaderElectionConfiguration configures the election of the replica
+// writing cluster-level state. Every replica serves admission requests
+// regardless.
+type LeaderElectionConfiguration struct {
+	// LeaderElect enables leader election.
+	LeaderElect bool `json:"leaderElect,omitempty"`
+	// ResourceName names the Lease of the leader, in the namespace of
+	// Mutato.
+	ResourceName string `json:"resourceName,omitempty"`
+}
+
+// MutationConfiguration configures how mutations are reported.
+type MutationConfiguration struct {
+	// Annotations stamps mutated objects with the applied mutators
+	// (gatekeeper.sh/mutations) and the mutation ID
+	// (gatekeeper.sh/mutation-id).
+	Annotations bool `json:"annotations,omitempty"`
+	// AuditAnnotations adds the applied mutators and the mutation ID to the
+	// audit annotations of admission responses.
+	AuditAnnotations bool `json:"auditAnnotations,omitempty"`
+	// Log logs every applied mutation.
+	Log bool `json:"log,omitempty"`
+}
+
+// BundlesConfiguration configures the OPA bundles of Dynamic objects.
+type BundlesConfiguration struct {
+	// Dir bundle paths of Dynamic objects are resolved against. Empty
+	// disables bundle paths.
+	Dir string `json:"dir,omitempty"`
+	// PollInterval is how often bundle sources are checked for changes.
+	PollInterval metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// DecisionLogConfiguration configures the decision log.
+type DecisionLogConfiguration struct {
+	// Sink is where decisions are logged: stdout, file or http. Empty
+	// disables the decision log.
+	Sink string `json:"sink,omitempty"`
+	// File decisions are written to by the file sink.
+	File string `json:"file,omitempty"`
+	// FileMaxSizeMiB is the size in MiB at which the file is rotated.
+	FileMaxSizeMiB int64 `json:"fileMaxSizeMiB,omitempty"`
+	// FileMaxBackups is the number of rotated files kept.
+	FileMaxBackups int `json:"fileMaxBackups,omitempty"`
+	// URL batches of decisions are posted to by the http sink.
+	URL string `json:"url,omitempty"`
+	// BufferSize is the number of decisions buffered before new ones are
+	// dropped.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// BatchSize is the maximum number of decisions written at once.
+	BatchSize int `json:"batchSize,omitempty"`
+	// FlushInterval is how often buffered decisions are written.
+	FlushInterval metav1.Duration `json:"flushInterval,omitempty"`
+}
+
+// RedactionConfiguration configures the masking of sensitive values in
+// logs, dry runs and decisions.
+type RedactionConfiguration struct {
+	// EnvNames is a regular expression matching the names of environment
+	// variables whose values are masked. Empty masks none.
+	EnvNames string `json:"envNames,omitempty"`
+}
+
+// TracingConfiguration configures the export of traces.
+type TracingConfiguration struct {
+	// Endpoint is the OTLP gRPC endpoint traces are exported to. Empty
+	// disables tracing.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Insecure exports traces without TLS.
+	Insecure bool `json:"insecure,omitempty"`
+	// SampleRatio is the fraction of admission requests traced, unless the
+	// API server already decided to sample them.
+	SampleRatio float64 `json:"sampleRatio,omitempty"`
+}
+
+// DebugConfiguration configures the debug endpoints of the webhook server,
+// /debug/mutators and /debug/rego. They are served to users allowed to get
+// their path as a non-resource URL.
+type DebugConfiguration struct {
+	// Enabled serves the debug endpoints. Defaults to false.
+	Enabled bool `json:"enabled,omitempty"`
+}
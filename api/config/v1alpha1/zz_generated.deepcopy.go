@@ -0,0 +1,211 @@
+//go:build !ignore_autogenerated
+
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundlesConfiguration) DeepCopyInto(out *BundlesConfiguration) {
+	*out = *in
+	out.PollInterval = in.PollInterval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundlesConfiguration.
+func (in *BundlesConfiguration) DeepCopy() *BundlesConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(BundlesConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DebugConfiguration) DeepCopyInto(out *DebugConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DebugConfiguration.
+func (in *DebugConfiguration) DeepCopy() *DebugConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(DebugConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DecisionLogConfiguration) DeepCopyInto(out *DecisionLogConfiguration) {
+	*out = *in
+	out.FlushInterval = in.FlushInterval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DecisionLogConfiguration.
+func (in *DecisionLogConfiguration) DeepCopy() *DecisionLogConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(DecisionLogConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthConfiguration) DeepCopyInto(out *HealthConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthConfiguration.
+func (in *HealthConfiguration) DeepCopy() *HealthConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LeaderElectionConfiguration) DeepCopyInto(out *LeaderElectionConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeaderElectionConfiguration.
+func (in *LeaderElectionConfiguration) DeepCopy() *LeaderElectionConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(LeaderElectionConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsConfiguration) DeepCopyInto(out *MetricsConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfiguration.
+func (in *MetricsConfiguration) DeepCopy() *MetricsConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MutationConfiguration) DeepCopyInto(out *MutationConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MutationConfiguration.
+func (in *MutationConfiguration) DeepCopy() *MutationConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(MutationConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedactionConfiguration) DeepCopyInto(out *RedactionConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedactionConfiguration.
+func (in *RedactionConfiguration) DeepCopy() *RedactionConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(RedactionConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingConfiguration) DeepCopyInto(out *TracingConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingConfiguration.
+func (in *TracingConfiguration) DeepCopy() *TracingConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookConfiguration) DeepCopyInto(out *WebhookConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookConfiguration.
+func (in *WebhookConfiguration) DeepCopy() *WebhookConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookServerConfiguration) DeepCopyInto(out *WebhookServerConfiguration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.Webhook = in.Webhook
+	out.Metrics = in.Metrics
+	out.Health = in.Health
+	out.LeaderElection = in.LeaderElection
+	out.Mutation = in.Mutation
+	out.Bundles = in.Bundles
+	out.DecisionLog = in.DecisionLog
+	out.Redaction = in.Redaction
+	out.Tracing = in.Tracing
+	out.Debug = in.Debug
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookServerConfiguration.
+func (in *WebhookServerConfiguration) DeepCopy() *WebhookServerConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookServerConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WebhookServerConfiguration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
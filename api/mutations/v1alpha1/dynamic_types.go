@@ -17,19 +17,240 @@ package v1alpha1
 
 import (
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// +kubebuilder:validation:XValidation:rule="[has(self.rego), has(self.bundle), has(self.templateRef)].filter(x, x).size() == 1",message="exactly one of rego, bundle and templateRef must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.parameters) || has(self.templateRef)",message="parameters require templateRef"
+
 type DynamicSpec struct {
 	// Match allows the user to limit which resources get mutated.
 	// Individual match criteria are AND-ed together. An undefined
 	// match criteria matches everything.
 	Match match.Match `json:"match,omitempty"`
 
-	Rego string `json:"rego"`
+	// Rego is the rule. Whatever `data.mutating.modified` evaluates to
+	// replaces the object. The optional `data.mutating.warnings` set of
+	// strings is returned to the client as admission warnings, and the
+	// optional `data.mutating.deny` set of strings rejects the object if
+	// DenyPolicy is Deny.
+	Rego string `json:"rego,omitempty"`
+
+	// Bundle loads the policy from an OPA bundle instead of Rego. The
+	// bundle is reloaded when its source changes.
+	Bundle *BundleSource `json:"bundle,omitempty"`
+
+	// TemplateRef names the DynamicTemplate providing the rule.
+	TemplateRef string `json:"templateRef,omitempty"`
+
+	// Parameters are validated against the schema of the template and
+	// passed to its rule as `data.parameters`, not as `input.parameters`.
+	Parameters *apiextensionsv1.JSON `json:"parameters,omitempty"`
+
+	// EnforcementAction decides whether the changes of the rule are
+	// applied. Defaults to apply.
+	EnforcementAction EnforcementAction `json:"enforcementAction,omitempty"`
+
+	// FailurePolicy decides what happens to the admission request when the
+	// rule fails, e.g. because it can not be evaluated or its output is
+	// invalid. Defaults to Fail.
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// CircuitBreaker suspends the rule while it keeps failing. The rule is
+	// never suspended if unset.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// DenyPolicy decides whether the messages of `data.mutating.deny`
+	// reject the object. Defaults to Ignore.
+	DenyPolicy DenyPolicy `json:"denyPolicy,omitempty"`
+
+	// ExposeSecretData passes the data and stringData values of Secrets to
+	// the rule. Otherwise the rule sees masked values, and masked values it
+	// returns unchanged keep their original value.
+	ExposeSecretData bool `json:"exposeSecretData,omitempty"`
+}
+
+// EnforcementAction decides what happens to the changes of a rule.
+// +kubebuilder:validation:Enum=apply;dryrun;warn
+type EnforcementAction string
+
+const (
+	// EnforcementActionApply applies the changes.
+	EnforcementActionApply EnforcementAction = "apply"
+	// EnforcementActionDryRun leaves the object unchanged and records the
+	// JSON patch that would have been applied in logs, metrics and status.
+	EnforcementActionDryRun EnforcementAction = "dryrun"
+	// EnforcementActionWarn behaves like dryrun and also returns an
+	// admission warning describing the changes to the client.
+	EnforcementActionWarn EnforcementAction = "warn"
+)
+
+// FailurePolicy decides what happens when a rule fails.
+// +kubebuilder:validation:Enum=Fail;Ignore
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail rejects the admission request.
+	FailurePolicyFail FailurePolicy = "Fail"
+	// FailurePolicyIgnore skips the rule and applies the other mutators. The
+	// failure is returned as an admission warning.
+	FailurePolicyIgnore FailurePolicy = "Ignore"
+)
+
+// DenyPolicy decides what happens when a rule denies an object.
+// +kubebuilder:validation:Enum=Deny;Ignore
+type DenyPolicy string
+
+const (
+	// DenyPolicyDeny rejects the admission request with the messages of the
+	// rule instead of patching the object. With the dryrun and warn
+	// enforcement actions, the object is only reported as denied.
+	DenyPolicyDeny DenyPolicy = "Deny"
+	// DenyPolicyIgnore only logs the messages of the rule.
+	DenyPolicyIgnore DenyPolicy = "Ignore"
+)
+
+// CircuitBreaker configures when a failing rule is suspended. While
+// suspended, the rule is removed from the mutation system and the Dynamic is
+// marked Degraded. After Cooldown the rule is enabled again and the next
+// evaluation decides whether it stays enabled.
+type CircuitBreaker struct {
+	// ErrorRate is the percentage of failed evaluations within Window at
+	// which the rule is suspended. Defaults to 50.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=50
+	ErrorRate int32 `json:"errorRate,omitempty"`
+
+	// MinEvaluations is the number of evaluations within Window below which
+	// the rule is never suspended. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	MinEvaluations int32 `json:"minEvaluations,omitempty"`
+
+	// Window is the period the error rate is computed over. Defaults to 1m.
+	// +kubebuilder:default="1m"
+	Window metav1.Duration `json:"window,omitempty"`
+
+	// Cooldown is how long the rule stays suspended before it is probed.
+	// Defaults to 1m.
+	// +kubebuilder:default="1m"
+	Cooldown metav1.Duration `json:"cooldown,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="[has(self.configMap), has(self.secret), has(self.path)].filter(x, x).size() == 1",message="exactly one of configMap, secret and path must be set"
+
+// BundleSource locates an OPA bundle.
+type BundleSource struct {
+	// ConfigMap holds the bundle tarball (.tar.gz) in binaryData or data.
+	ConfigMap *BundleObjectReference `json:"configMap,omitempty"`
+
+	// Secret holds the bundle tarball (.tar.gz).
+	Secret *BundleObjectReference `json:"secret,omitempty"`
+
+	// Path is a directory below the webhook's bundle directory holding
+	// either an extracted bundle or an OCI image layout, e.g. a mounted
+	// volume or image.
+	Path string `json:"path,omitempty"`
+
+	// Verification requires the bundle to be signed by one of the given
+	// keys. Unsigned bundles are rejected.
+	Verification *BundleVerification `json:"verification,omitempty"`
+}
+
+// BundleObjectReference references an entry of a ConfigMap or Secret.
+type BundleObjectReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// Key of the entry holding the bundle. Defaults to `bundle.tar.gz`.
+	Key string `json:"key,omitempty"`
+}
+
+// BundleVerification configures bundle signature verification.
+type BundleVerification struct {
+	// KeysSecret holds the verification keys, keyed by key ID. Public keys
+	// are PEM encoded; HMAC algorithms use the entry as the shared secret.
+	KeysSecret SecretReference `json:"keysSecret"`
+
+	// Algorithm of the keys. Defaults to RS256.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// KeyID is the key used if the signature does not name one.
+	KeyID string `json:"keyID,omitempty"`
+
+	// Scope the signature must carry, if any.
+	Scope string `json:"scope,omitempty"`
+
+	// Exclude lists bundle files, as path.Match patterns, that are not
+	// covered by the signature.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// SecretReference references a Secret.
+type SecretReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
 }
 
 type DynamicStatus struct {
+	// DryRun describes the last change the rule would have applied while
+	// not enforced, on any replica.
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// Conditions describe the state of the rule. The Degraded condition is
+	// true while the rule is suspended by its circuit breaker on any
+	// replica.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ByPod is the state of the rule on each replica of the webhook. Every
+	// replica writes its own entry; the leader aggregates them into DryRun
+	// and Conditions and removes the entries of replicas that are gone.
+	// +listType=map
+	// +listMapKey=id
+	ByPod []DynamicPodStatus `json:"byPod,omitempty"`
+}
+
+// DynamicPodStatus is the state of a rule on one replica of the webhook.
+type DynamicPodStatus struct {
+	// ID is the name of the pod of the replica.
+	ID string `json:"id"`
+
+	// ObservedGeneration is the generation of the Dynamic last ingested.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Active is true if the rule is part of the mutation system of the
+	// replica.
+	Active bool `json:"active"`
+
+	// Suspended is true while the circuit breaker of the replica suspends
+	// the rule.
+	Suspended bool `json:"suspended,omitempty"`
+
+	// Message explains why the rule is suspended.
+	Message string `json:"message,omitempty"`
+
+	// DryRun describes the last change the rule would have applied on the
+	// replica while not enforced.
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+}
+
+const (
+	// ConditionDegraded is true while a Dynamic is suspended.
+	ConditionDegraded = "Degraded"
+)
+
+// DryRunStatus describes a change that was not applied.
+type DryRunStatus struct {
+	// Object is the kind, namespace and name of the admitted object.
+	Object string `json:"object"`
+	// Patch is the JSON patch that would have been applied.
+	Patch string `json:"patch"`
+	// Time the object was admitted.
+	Time metav1.Time `json:"time"`
 }
 
 // +kubebuilder:object:root=true
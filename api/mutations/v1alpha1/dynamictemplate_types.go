@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DynamicTemplateSpec holds a Rego rule shared by the Dynamic objects
+// instantiating it with spec.templateRef.
+type DynamicTemplateSpec struct {
+	// Rego is the rule of every instance. The parameters of an instance are
+	// only available as `data.parameters`; they are not merged into
+	// `input`, which remains the object, as whatever
+	// `data.mutating.modified` evaluates to replaces it.
+	// `data.mutating.warnings` and `data.mutating.deny` behave as for Dynamic.
+	Rego string `json:"rego"`
+
+	// Parameters is the OpenAPI v3 schema the parameters of instances are
+	// validated against. Instances may pass any parameters if it is unset.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Parameters *apiextensionsv1.JSONSchemaProps `json:"parameters,omitempty"`
+}
+
+type DynamicTemplateStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="dynamictemplates"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type DynamicTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DynamicTemplateSpec   `json:"spec,omitempty"`
+	Status DynamicTemplateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DynamicTemplateList contains a list of DynamicTemplate.
+type DynamicTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DynamicTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DynamicTemplate{}, &DynamicTemplateList{})
+}
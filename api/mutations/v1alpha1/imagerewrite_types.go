@@ -0,0 +1,94 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ImageRewritesAnnotation records every image rewritten on an object as a
+	// JSON object keyed by container name.
+	ImageRewritesAnnotation = "mutato.kubesphere.io/image-rewrites"
+)
+
+type ImageRewriteSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Pods and objects embedding
+	// a pod template are rewritten.
+	Match match.Match `json:"match,omitempty"`
+
+	// ContainerTypes limits the rewrite to the given container lists.
+	// Defaults to all of them.
+	ContainerTypes []ContainerType `json:"containerTypes,omitempty"`
+
+	// Rules are evaluated in order against the fully qualified image
+	// repository, e.g. `docker.io/library/nginx` for `nginx:1.27`.
+	// The first matching rule wins. Tags and digests are preserved.
+	// +kubebuilder:validation:MinItems=1
+	Rules []ImageRewriteRule `json:"rules"`
+
+	// ImagePullSecrets are added to the pod spec whenever an image
+	// was rewritten.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// ImageRewriteRule rewrites an image repository. Exactly one of Prefix
+// and Regex must be set.
+type ImageRewriteRule struct {
+	// Prefix matches repositories starting with it, e.g. `docker.io/`.
+	// The matched prefix is replaced by Replacement.
+	Prefix string `json:"prefix,omitempty"`
+	// Regex matches repositories with a regular expression. Every match
+	// is replaced by Replacement, which may reference capture groups such
+	// as `$1`; anchor the expression with `^` and `$` to replace the
+	// repository as a whole.
+	Regex string `json:"regex,omitempty"`
+	// Replacement is the new value for the matched part of the repository.
+	Replacement string `json:"replacement"`
+}
+
+type ImageRewriteStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="imagerewrites"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type ImageRewrite struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageRewriteSpec   `json:"spec,omitempty"`
+	Status ImageRewriteStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ImageRewriteList contains a list of ImageRewrite.
+type ImageRewriteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageRewrite `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImageRewrite{}, &ImageRewriteList{})
+}
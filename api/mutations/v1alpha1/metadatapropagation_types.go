@@ -0,0 +1,98 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PropagationSource is the object metadata is copied from.
+// +kubebuilder:validation:Enum=Namespace;Workload;Workspace
+type PropagationSource string
+
+const (
+	// PropagationSourceNamespace copies from the namespace of the object.
+	PropagationSourceNamespace PropagationSource = "Namespace"
+	// PropagationSourceWorkload copies from the top-most controller of the
+	// object, following controller owner references, e.g. the Deployment
+	// owning the ReplicaSet owning a Pod.
+	PropagationSourceWorkload PropagationSource = "Workload"
+	// PropagationSourceWorkspace copies from the KubeSphere workspace the
+	// namespace of the object belongs to.
+	PropagationSourceWorkspace PropagationSource = "Workspace"
+)
+
+// PropagatedKeys selects metadata keys to copy.
+type PropagatedKeys struct {
+	// Keys are the keys copied from the source. A key may start or end
+	// with `*` to match every key with the given suffix or prefix, e.g.
+	// `team.example.com/*`.
+	Keys []string `json:"keys,omitempty"`
+
+	// Rename maps source keys to the key written on the object. Keys that
+	// are not listed keep their name.
+	Rename map[string]string `json:"rename,omitempty"`
+}
+
+// PropagationRule copies labels and annotations from one source.
+type PropagationRule struct {
+	From PropagationSource `json:"from"`
+
+	Labels      PropagatedKeys `json:"labels,omitempty"`
+	Annotations PropagatedKeys `json:"annotations,omitempty"`
+}
+
+// MetadataPropagationSpec describes labels and annotations copied onto
+// objects at admission. Keys already present on the object are never
+// overwritten; when several rules write the same key, the first one wins.
+type MetadataPropagationSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything.
+	Match match.Match `json:"match,omitempty"`
+
+	Rules []PropagationRule `json:"rules,omitempty"`
+}
+
+type MetadataPropagationStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="metadatapropagations"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type MetadataPropagation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetadataPropagationSpec   `json:"spec,omitempty"`
+	Status MetadataPropagationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MetadataPropagationList contains a list of MetadataPropagation.
+type MetadataPropagationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetadataPropagation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetadataPropagation{}, &MetadataPropagationList{})
+}
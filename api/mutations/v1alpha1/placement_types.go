@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// WorkspaceLabel is the namespace label holding the name of the
+	// KubeSphere workspace the namespace belongs to.
+	WorkspaceLabel = "kubesphere.io/workspace"
+)
+
+// PlacementSpec describes scheduling constraints injected into pods.
+//
+// String values may reference the pod's namespace and workspace with Go
+// templates, e.g. `{{ index .NamespaceLabels "node-pool" }}` or
+// `{{ index .WorkspaceLabels "node-pool" }}`. `.Namespace` and `.Workspace`
+// hold the names and `.Labels` the pod labels. An entry whose template
+// renders to an empty string is skipped, so rules can be driven by labels
+// that only some namespaces carry.
+type PlacementSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Only Pods are ever mutated.
+	Match match.Match `json:"match,omitempty"`
+
+	// NodeSelector entries are added unless the pod already selects on the key.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// RequiredNodeAffinity requirements are AND-ed into every required node
+	// affinity term of the pod, or added as a new term if it has none.
+	RequiredNodeAffinity []corev1.NodeSelectorRequirement `json:"requiredNodeAffinity,omitempty"`
+
+	// Tolerations are added unless an identical toleration exists.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// PriorityClassName is set if the pod has none.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// TopologySpreadConstraints are added unless the pod already has a
+	// constraint for the same topologyKey and whenUnsatisfiable.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+type PlacementStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="placements"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type Placement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PlacementSpec   `json:"spec,omitempty"`
+	Status PlacementStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PlacementList contains a list of Placement.
+type PlacementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Placement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Placement{}, &PlacementList{})
+}
@@ -0,0 +1,121 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConflictStrategy decides what happens when an injected entry collides
+// with one already present in the pod.
+// +kubebuilder:validation:Enum=Skip;Override;Fail
+type ConflictStrategy string
+
+const (
+	// ConflictStrategySkip keeps the entry already present in the pod.
+	ConflictStrategySkip ConflictStrategy = "Skip"
+	// ConflictStrategyOverride replaces the entry present in the pod, unless
+	// another PodPreset evaluated before injected it.
+	ConflictStrategyOverride ConflictStrategy = "Override"
+	// ConflictStrategyFail rejects the pod.
+	ConflictStrategyFail ConflictStrategy = "Fail"
+)
+
+// ContainerSelector selects containers by name and image. Both fields
+// accept shell patterns as understood by path.Match, e.g. `*/nginx:*`.
+// Empty fields match every container.
+type ContainerSelector struct {
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+type PodPresetSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Only Pods in the namespace
+	// of the PodPreset are ever mutated.
+	Match match.Match `json:"match,omitempty"`
+
+	// Selector is a label selector against the pod. It is AND-ed with Match.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// ContainerTypes limits the preset to the given container lists.
+	// Defaults to all of them.
+	ContainerTypes []ContainerType `json:"containerTypes,omitempty"`
+
+	// Containers selects the containers the preset is injected into. A
+	// container matching any selector is selected. Defaults to all containers.
+	Containers []ContainerSelector `json:"containers,omitempty"`
+
+	// Env is merged into the env of every selected container, keyed by name.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom is appended to the envFrom of every selected container unless
+	// an identical source is present.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Volumes are merged into the pod's volumes, keyed by name.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts are merged into the volumeMounts of every selected
+	// container, keyed by mountPath.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// ConflictStrategy decides what happens when an env var, volume or
+	// volume mount differs from one already present in the pod. Entries
+	// injected by a PodPreset evaluated before are never overridden.
+	// Defaults to Skip.
+	ConflictStrategy ConflictStrategy `json:"conflictStrategy,omitempty"`
+}
+
+type PodPresetStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="podpresets"
+// +kubebuilder:resource:scope="Namespaced"
+// +kubebuilder:subresource:status
+
+type PodPreset struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodPresetSpec   `json:"spec,omitempty"`
+	Status PodPresetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PodPresetList contains a list of PodPreset.
+type PodPresetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodPreset `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodPreset{}, &PodPresetList{})
+}
@@ -0,0 +1,85 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PodSecurityEnforceLabel is the namespace label of the Pod Security
+	// Admission controller that selects the enforced level.
+	PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+)
+
+// PodSecurityLevel is a Pod Security Standards profile.
+// +kubebuilder:validation:Enum=privileged;baseline;restricted
+type PodSecurityLevel string
+
+const (
+	PodSecurityLevelPrivileged PodSecurityLevel = "privileged"
+	PodSecurityLevelBaseline   PodSecurityLevel = "baseline"
+	PodSecurityLevelRestricted PodSecurityLevel = "restricted"
+)
+
+type PodSecurityDefaultSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Pods and objects embedding
+	// a pod template are mutated.
+	Match match.Match `json:"match,omitempty"`
+
+	// DefaultLevel is used for namespaces without the
+	// `pod-security.kubernetes.io/enforce` label. When empty, pods in
+	// such namespaces are left alone.
+	DefaultLevel PodSecurityLevel `json:"defaultLevel,omitempty"`
+}
+
+type PodSecurityDefaultStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="podsecuritydefaults"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+// PodSecurityDefault fills in securityContext fields that are missing from a
+// pod so it complies with the Pod Security Standard enforced in its namespace.
+// Both baseline and restricted default the pod seccompProfile to RuntimeDefault;
+// restricted also sets runAsNonRoot, allowPrivilegeEscalation: false and drops
+// ALL capabilities. Values set on the pod are never overridden; a container
+// dropping capabilities without ALL is reported in an admission warning.
+type PodSecurityDefault struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodSecurityDefaultSpec   `json:"spec,omitempty"`
+	Status PodSecurityDefaultStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PodSecurityDefaultList contains a list of PodSecurityDefault.
+type PodSecurityDefaultList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodSecurityDefault `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodSecurityDefault{}, &PodSecurityDefaultList{})
+}
@@ -0,0 +1,108 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContainerType selects one of the container lists of a pod spec.
+// +kubebuilder:validation:Enum=Containers;InitContainers;EphemeralContainers
+type ContainerType string
+
+const (
+	ContainerTypeContainers          ContainerType = "Containers"
+	ContainerTypeInitContainers      ContainerType = "InitContainers"
+	ContainerTypeEphemeralContainers ContainerType = "EphemeralContainers"
+)
+
+type ResourcePolicySpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Only Pods are ever mutated.
+	Match match.Match `json:"match,omitempty"`
+
+	// ContainerTypes limits the policy to the given container lists.
+	// Defaults to Containers and InitContainers. EphemeralContainers is
+	// rejected, as ephemeral containers cannot have resources.
+	// +kubebuilder:validation:XValidation:rule="self.all(t, t != 'EphemeralContainers')",message="ephemeral containers cannot have resources"
+	ContainerTypes []ContainerType `json:"containerTypes,omitempty"`
+
+	// Rules is the list of per-resource rules, at most one per resource.
+	// +listType=map
+	// +listMapKey=resource
+	Rules []ResourceRule `json:"rules"`
+}
+
+// ResourceRule describes how requests and limits of a single resource
+// are defaulted and bounded for every container. Rules are applied in
+// the order defaults, min/max, request-to-limit ratio.
+type ResourceRule struct {
+	// Resource is the name of the resource the rule applies to.
+	// +kubebuilder:validation:Enum=cpu;memory;ephemeral-storage
+	Resource corev1.ResourceName `json:"resource"`
+
+	// DefaultRequest is set on containers that have no request.
+	DefaultRequest *resource.Quantity `json:"defaultRequest,omitempty"`
+	// DefaultLimit is set on containers that have no limit.
+	DefaultLimit *resource.Quantity `json:"defaultLimit,omitempty"`
+
+	// Min raises requests and limits below it.
+	Min *resource.Quantity `json:"min,omitempty"`
+	// Max lowers requests and limits above it.
+	Max *resource.Quantity `json:"max,omitempty"`
+
+	// MinRequestToLimitRatio raises the request to at least limit * ratio,
+	// e.g. "0.5". Only applied when the container has a limit.
+	MinRequestToLimitRatio *resource.Quantity `json:"minRequestToLimitRatio,omitempty"`
+	// MaxRequestToLimitRatio lowers the request to at most limit * ratio.
+	// Only applied when the container has a limit. A container with a limit
+	// but no request gets a request of limit * ratio, instead of the limit
+	// the API server would default it to.
+	MaxRequestToLimitRatio *resource.Quantity `json:"maxRequestToLimitRatio,omitempty"`
+}
+
+type ResourcePolicyStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="resourcepolicies"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type ResourcePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourcePolicySpec   `json:"spec,omitempty"`
+	Status ResourcePolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourcePolicyList contains a list of ResourcePolicy.
+type ResourcePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourcePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourcePolicy{}, &ResourcePolicyList{})
+}
@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SidecarInjectAnnotation opts a pod out of sidecar injection when set to "false".
+	SidecarInjectAnnotation = "sidecar.mutato.kubesphere.io/inject"
+)
+
+type SidecarInjectionSpec struct {
+	// Match allows the user to limit which resources get mutated.
+	// Individual match criteria are AND-ed together. An undefined
+	// match criteria matches everything. Only Pods are ever injected.
+	Match match.Match `json:"match,omitempty"`
+
+	// Selector is a label selector against the pod. It is AND-ed with Match.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Containers are appended to the pod's containers. A container whose
+	// name is already present in the pod is left untouched.
+	// String fields may reference pod metadata with Go templates, e.g.
+	// `{{ .Namespace }}` or `{{ index .Labels "app" }}`.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Containers []corev1.Container `json:"containers,omitempty"`
+
+	// InitContainers are prepended to the pod's initContainers, keeping their
+	// relative order. Set `restartPolicy: Always` to inject a native sidecar.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Volumes are appended to the pod's volumes unless a volume with the
+	// same name already exists.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+}
+
+type SidecarInjectionStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path="sidecarinjections"
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+
+type SidecarInjection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SidecarInjectionSpec   `json:"spec,omitempty"`
+	Status SidecarInjectionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SidecarInjectionList contains a list of SidecarInjection.
+type SidecarInjectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SidecarInjection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SidecarInjection{}, &SidecarInjectionList{})
+}
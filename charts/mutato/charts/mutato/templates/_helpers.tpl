@@ -60,3 +60,32 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Render the configuration file of the webhook server from the values; fields
+of .Values.config override the rendered ones
+*/}}
+{{- define "mutato-webhook.config" -}}
+{{- $config := dict
+  "apiVersion" "config.mutato.kubesphere.io/v1alpha1"
+  "kind" "WebhookServerConfiguration"
+  "webhook" (dict
+    "port" .Values.service.port
+    "serviceAccount" (include "mutato-webhook.serviceAccountName" .)
+    "notReadyPolicy" .Values.notReadyPolicy)
+  "metrics" (dict "bindAddress" (printf ":%v" .Values.metrics.port))
+  "health" (dict "probeBindAddress" (printf ":%v" .Values.probes.port))
+  "leaderElection" (dict "leaderElect" .Values.leaderElection)
+  "mutation" (dict
+    "annotations" .Values.mutation.annotations
+    "auditAnnotations" .Values.mutation.auditAnnotations
+    "log" .Values.mutation.log)
+  "decisionLog" (dict "sink" .Values.decisionLog.sink "url" .Values.decisionLog.url)
+  "tracing" .Values.tracing
+  "debug" (dict "enabled" .Values.debug.enabled)
+-}}
+{{- with .Values.redaction.envNames }}
+{{- $_ := set $config "redaction" (dict "envNames" .) }}
+{{- end }}
+{{- toYaml (mergeOverwrite $config (deepCopy .Values.config)) }}
+{{- end }}
@@ -0,0 +1,177 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	configv1alpha1 "kubesphere.io/muato/api/config/v1alpha1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+// configFlags binds the flags overriding the fields of the configuration.
+type configFlags struct {
+	fs *flag.FlagSet
+}
+
+// bindConfigFlags adds the flags of the fields of cfg to fs. Their defaults
+// are the values of cfg.
+func bindConfigFlags(fs *flag.FlagSet, cfg *configv1alpha1.WebhookServerConfiguration) *configFlags {
+	own := flag.NewFlagSet("config", flag.ContinueOnError)
+	own.IntVar(&cfg.Webhook.Port, "webhook-port", cfg.Webhook.Port, "Port the webhook server listens on.")
+	own.StringVar(&cfg.Webhook.CertDir, "cert-dir", cfg.Webhook.CertDir, "Directory containing the serving certificate, tls.crt and tls.key.")
+	own.StringVar(&cfg.Webhook.ServiceAccount, "service-account", cfg.Webhook.ServiceAccount, "Service account of Mutato in its namespace, whose requests are never mutated.")
+	own.StringVar((*string)(&cfg.Webhook.NotReadyPolicy), "not-ready-policy", string(cfg.Webhook.NotReadyPolicy), "How admission requests are handled until the existing mutators are ingested: Fail fails them, Ignore admits them unmutated.")
+	own.StringVar(&cfg.Metrics.BindAddress, "metrics-bind-address", cfg.Metrics.BindAddress, "Address the Prometheus metrics endpoint binds to. \"0\" disables it.")
+	own.StringVar(&cfg.Health.ProbeBindAddress, "health-probe-bind-address", cfg.Health.ProbeBindAddress, "Address the /healthz and /readyz probe endpoints bind to.")
+	own.BoolVar(&cfg.LeaderElection.LeaderElect, "leader-elect", cfg.LeaderElection.LeaderElect, "Elect a leader among the replicas to write cluster-level state. Every replica serves admission requests regardless.")
+	own.StringVar(&cfg.LeaderElection.ResourceName, "leader-election-id", cfg.LeaderElection.ResourceName, "Name of the Lease of the leader, in the namespace of Mutato.")
+	// --mutation-annotations and --log-mutations are registered by the
+	// mutation package.
+	own.BoolVar(&cfg.Mutation.AuditAnnotations, "audit-annotations", cfg.Mutation.AuditAnnotations, "Add the applied mutators and the mutation ID to the audit annotations of admission responses.")
+	own.StringVar(&cfg.Bundles.Dir, "bundle-dir", cfg.Bundles.Dir, "Directory bundle paths of Dynamic objects are resolved against. Empty disables bundle paths.")
+	own.DurationVar(&cfg.Bundles.PollInterval.Duration, "bundle-poll-interval", cfg.Bundles.PollInterval.Duration, "How often bundle sources are checked for changes.")
+	own.StringVar(&cfg.DecisionLog.Sink, "decision-log-sink", cfg.DecisionLog.Sink, "Where decisions are logged: stdout, file or http. Empty disables the decision log.")
+	own.StringVar(&cfg.DecisionLog.File, "decision-log-file", cfg.DecisionLog.File, "File decisions are written to by the file sink.")
+	own.Int64Var(&cfg.DecisionLog.FileMaxSizeMiB, "decision-log-file-max-size", cfg.DecisionLog.FileMaxSizeMiB, "Size in MiB at which the decision log file is rotated.")
+	own.IntVar(&cfg.DecisionLog.FileMaxBackups, "decision-log-file-max-backups", cfg.DecisionLog.FileMaxBackups, "Number of rotated decision log files kept.")
+	own.StringVar(&cfg.DecisionLog.URL, "decision-log-url", cfg.DecisionLog.URL, "URL batches of decisions are posted to by the http sink.")
+	own.IntVar(&cfg.DecisionLog.BufferSize, "decision-log-buffer-size", cfg.DecisionLog.BufferSize, "Number of decisions buffered before new ones are dropped.")
+	own.IntVar(&cfg.DecisionLog.BatchSize, "decision-log-batch-size", cfg.DecisionLog.BatchSize, "Maximum number of decisions written at once.")
+	own.DurationVar(&cfg.DecisionLog.FlushInterval.Duration, "decision-log-flush-interval", cfg.DecisionLog.FlushInterval.Duration, "How often buffered decisions are written.")
+	own.StringVar(&cfg.Redaction.EnvNames, "redact-env-names", cfg.Redaction.EnvNames, "Regular expression matching the names of environment variables whose values are masked in logs, dry runs and decisions. Empty masks none.")
+	own.StringVar(&cfg.Tracing.Endpoint, "tracing-endpoint", cfg.Tracing.Endpoint, "OTLP gRPC endpoint traces are exported to, e.g. otel-collector.observability:4317. Empty disables tracing.")
+	own.BoolVar(&cfg.Tracing.Insecure, "tracing-insecure", cfg.Tracing.Insecure, "Export traces without TLS.")
+	own.Float64Var(&cfg.Tracing.SampleRatio, "tracing-sample-ratio", cfg.Tracing.SampleRatio, "Fraction of admission requests traced, unless the API server already decided to sample them.")
+	own.BoolVar(&cfg.Debug.Enabled, "debug-endpoints", cfg.Debug.Enabled, "Serve the state of the mutators at /debug/mutators and their Rego at /debug/rego on the webhook server, to users allowed to get these non-resource URLs.")
+	own.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return &configFlags{fs: own}
+}
+
+// load decodes the configuration file at path, if any, onto cfg, and then
+// applies the flags given in fs again so that they override the file. It
+// must be called once fs is parsed.
+func (c *configFlags) load(fs *flag.FlagSet, path string, cfg *configv1alpha1.WebhookServerConfiguration) error {
+	// The mutation package binds its flags to its own variables.
+	cfg.Mutation.Annotations = *mutation.MutationAnnotationsEnabled
+	cfg.Mutation.Log = *mutation.MutationLoggingEnabled
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := decodeConfig(data, cfg); err != nil {
+			return fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	for name, value := range given {
+		switch {
+		case c.fs.Lookup(name) != nil:
+			if err := fs.Set(name, value); err != nil {
+				return err
+			}
+		case name == "mutation-annotations":
+			cfg.Mutation.Annotations = *mutation.MutationAnnotationsEnabled
+		case name == "log-mutations":
+			cfg.Mutation.Log = *mutation.MutationLoggingEnabled
+		}
+	}
+	*mutation.MutationLoggingEnabled = cfg.Mutation.Log
+	return nil
+}
+
+// decodeConfig decodes data onto cfg, rejecting unknown fields and other
+// kinds or versions.
+func decodeConfig(data []byte, cfg *configv1alpha1.WebhookServerConfiguration) error {
+	var file configv1alpha1.WebhookServerConfiguration
+	if err := yaml.Unmarshal(data, &file.TypeMeta); err != nil {
+		return err
+	}
+	if file.APIVersion != configv1alpha1.GroupVersion.String() || file.Kind != configv1alpha1.WebhookServerConfigurationKind {
+		return fmt.Errorf("expected apiVersion %s and kind %s, got %q and %q",
+			configv1alpha1.GroupVersion, configv1alpha1.WebhookServerConfigurationKind, file.APIVersion, file.Kind)
+	}
+	return yaml.UnmarshalStrict(data, cfg)
+}
+
+// validateConfig returns the invalid fields of cfg.
+func validateConfig(cfg *configv1alpha1.WebhookServerConfiguration) field.ErrorList {
+	var errs field.ErrorList
+
+	webhookPath := field.NewPath("webhook")
+	if cfg.Webhook.Port <= 0 || cfg.Webhook.Port > 65535 {
+		errs = append(errs, field.Invalid(webhookPath.Child("port"), cfg.Webhook.Port, "must be between 1 and 65535"))
+	}
+	if cfg.Webhook.ServiceAccount == "" {
+		errs = append(errs, field.Required(webhookPath.Child("serviceAccount"), ""))
+	}
+	if policy := cfg.Webhook.NotReadyPolicy; policy != mutationsv1alpha1.FailurePolicyFail && policy != mutationsv1alpha1.FailurePolicyIgnore {
+		errs = append(errs, field.NotSupported(webhookPath.Child("notReadyPolicy"), policy,
+			[]string{string(mutationsv1alpha1.FailurePolicyFail), string(mutationsv1alpha1.FailurePolicyIgnore)}))
+	}
+
+	if cfg.LeaderElection.LeaderElect && cfg.LeaderElection.ResourceName == "" {
+		errs = append(errs, field.Required(field.NewPath("leaderElection", "resourceName"), "required by leader election"))
+	}
+
+	if cfg.Bundles.PollInterval.Duration <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("bundles", "pollInterval"), cfg.Bundles.PollInterval.Duration.String(), "must be positive"))
+	}
+
+	decisionLogPath := field.NewPath("decisionLog")
+	switch cfg.DecisionLog.Sink {
+	case "":
+	case "stdout", "file", "http":
+		if cfg.DecisionLog.Sink == "http" && cfg.DecisionLog.URL == "" {
+			errs = append(errs, field.Required(decisionLogPath.Child("url"), "required by the http sink"))
+		}
+		if cfg.DecisionLog.BufferSize <= 0 {
+			errs = append(errs, field.Invalid(decisionLogPath.Child("bufferSize"), cfg.DecisionLog.BufferSize, "must be positive"))
+		}
+		if cfg.DecisionLog.BatchSize <= 0 {
+			errs = append(errs, field.Invalid(decisionLogPath.Child("batchSize"), cfg.DecisionLog.BatchSize, "must be positive"))
+		}
+		if cfg.DecisionLog.FlushInterval.Duration <= 0 {
+			errs = append(errs, field.Invalid(decisionLogPath.Child("flushInterval"), cfg.DecisionLog.FlushInterval.Duration.String(), "must be positive"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(decisionLogPath.Child("sink"), cfg.DecisionLog.Sink, []string{"stdout", "file", "http"}))
+	}
+
+	if _, err := regexp.Compile(cfg.Redaction.EnvNames); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("redaction", "envNames"), cfg.Redaction.EnvNames, err.Error()))
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, field.Invalid(field.NewPath("tracing", "sampleRatio"), cfg.Tracing.SampleRatio, "must be between 0 and 1"))
+	}
+	return errs
+}
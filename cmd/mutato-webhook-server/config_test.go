@@ -0,0 +1,187 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	configv1alpha1 "kubesphere.io/muato/api/config/v1alpha1"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*configv1alpha1.WebhookServerConfiguration)
+		// want are the paths of the invalid fields.
+		want []string
+	}{{
+		name:   "defaults",
+		modify: func(*configv1alpha1.WebhookServerConfiguration) {},
+	}, {
+		name: "webhook",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.Webhook.Port = 65536
+			cfg.Webhook.ServiceAccount = ""
+			cfg.Webhook.NotReadyPolicy = "Retry"
+		},
+		want: []string{"webhook.port", "webhook.serviceAccount", "webhook.notReadyPolicy"},
+	}, {
+		name: "leader election without resource name",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.LeaderElection.LeaderElect = true
+			cfg.LeaderElection.ResourceName = ""
+		},
+		want: []string{"leaderElection.resourceName"},
+	}, {
+		name: "resource name without leader election",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.LeaderElection.ResourceName = ""
+		},
+	}, {
+		name: "bundle poll interval",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.Bundles.PollInterval = metav1.Duration{}
+		},
+		want: []string{"bundles.pollInterval"},
+	}, {
+		name: "unknown decision log sink",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.DecisionLog.Sink = "kafka"
+		},
+		want: []string{"decisionLog.sink"},
+	}, {
+		name: "http decision log",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.DecisionLog.Sink = "http"
+			cfg.DecisionLog.URL = ""
+			cfg.DecisionLog.BufferSize = 0
+			cfg.DecisionLog.BatchSize = -1
+			cfg.DecisionLog.FlushInterval = metav1.Duration{Duration: -time.Second}
+		},
+		want: []string{"decisionLog.url", "decisionLog.bufferSize", "decisionLog.batchSize", "decisionLog.flushInterval"},
+	}, {
+		name: "disabled decision log is not validated",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.DecisionLog.Sink = ""
+			cfg.DecisionLog.BatchSize = 0
+		},
+	}, {
+		name: "redaction pattern",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.Redaction.EnvNames = "("
+		},
+		want: []string{"redaction.envNames"},
+	}, {
+		name: "sample ratio",
+		modify: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.Tracing.SampleRatio = 1.5
+		},
+		want: []string{"tracing.sampleRatio"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := configv1alpha1.Default()
+			tt.modify(cfg)
+			var got []string
+			for _, err := range validateConfig(cfg) {
+				got = append(got, err.Field)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("validateConfig() fields (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		want    func(*configv1alpha1.WebhookServerConfiguration)
+	}{{
+		name: "overrides defaults",
+		data: `apiVersion: config.mutato.kubesphere.io/v1alpha1
+kind: WebhookServerConfiguration
+webhook:
+  port: 8443
+decisionLog:
+  sink: stdout
+`,
+		want: func(cfg *configv1alpha1.WebhookServerConfiguration) {
+			cfg.Webhook.Port = 8443
+			cfg.DecisionLog.Sink = "stdout"
+		},
+	}, {
+		name: "unknown field",
+		data: `apiVersion: config.mutato.kubesphere.io/v1alpha1
+kind: WebhookServerConfiguration
+webhook:
+  prot: 8443
+`,
+		wantErr: true,
+	}, {
+		name: "other kind",
+		data: `apiVersion: config.mutato.kubesphere.io/v1alpha1
+kind: Configuration
+`,
+		wantErr: true,
+	}, {
+		name:    "missing apiVersion",
+		data:    "kind: WebhookServerConfiguration\n",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := configv1alpha1.Default()
+			err := decodeConfig([]byte(tt.data), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeConfig() = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := configv1alpha1.Default()
+			tt.want(want)
+			if diff := cmp.Diff(want, cfg); diff != "" {
+				t.Errorf("decodeConfig() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	data, err := os.ReadFile("../../examples/webhook-server-config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := configv1alpha1.Default()
+	if err := decodeConfig(data, cfg); err != nil {
+		t.Fatalf("decodeConfig() = %v", err)
+	}
+	// The example shows every default.
+	if diff := cmp.Diff(configv1alpha1.Default(), cfg); diff != "" {
+		t.Errorf("example configuration differs from the defaults (-want +got):\n%s", diff)
+	}
+	if errs := validateConfig(cfg); len(errs) > 0 {
+		t.Errorf("validateConfig() = %v", errs)
+	}
+}
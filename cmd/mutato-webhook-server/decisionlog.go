@@ -0,0 +1,45 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"time"
+
+	configv1alpha1 "kubesphere.io/muato/api/config/v1alpha1"
+	"kubesphere.io/muato/pkg/decisionlog"
+)
+
+// httpSinkTimeout bounds each request of the HTTP decision log sink.
+const httpSinkTimeout = 10 * time.Second
+
+// newDecisionLogger returns the decision logger configured by c, or nil if
+// the decision log is disabled. c must be valid.
+func newDecisionLogger(c *configv1alpha1.DecisionLogConfiguration) *decisionlog.Logger {
+	var sink decisionlog.Sink
+	switch c.Sink {
+	case "stdout":
+		sink = decisionlog.NewWriterSink(os.Stdout)
+	case "file":
+		sink = decisionlog.NewFileSink(c.File, c.FileMaxSizeMiB<<20, c.FileMaxBackups)
+	case "http":
+		sink = decisionlog.NewHTTPSink(c.URL, httpSinkTimeout)
+	default:
+		return nil
+	}
+	return decisionlog.NewLogger(sink, c.BufferSize, c.BatchSize, c.FlushInterval.Duration)
+}
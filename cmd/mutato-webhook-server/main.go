@@ -17,28 +17,54 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation"
 	mutationtypes "github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/util"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	configv1alpha1 "kubesphere.io/muato/api/config/v1alpha1"
 	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
 	mutato "kubesphere.io/muato/pkg"
+	"kubesphere.io/muato/pkg/breaker"
+	"kubesphere.io/muato/pkg/bundles"
 	"kubesphere.io/muato/pkg/controller"
+	"kubesphere.io/muato/pkg/debug"
+	"kubesphere.io/muato/pkg/metrics"
 	"kubesphere.io/muato/pkg/mutators"
+	"kubesphere.io/muato/pkg/readiness"
+	"kubesphere.io/muato/pkg/redact"
+	"kubesphere.io/muato/pkg/schemas"
+	"kubesphere.io/muato/pkg/status"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"time"
 )
 
 var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
-const eventQueueSize = 1024
+const (
+	eventQueueSize = 1024
+	// statusInterval is how often observations of the webhook are written
+	// to the status of mutation objects.
+	statusInterval = 10 * time.Second
+	// schemaRefreshInterval is how long OpenAPI schemas are cached.
+	schemaRefreshInterval = 5 * time.Minute
+)
 
 func main() {
+	cfg := configv1alpha1.Default()
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "Configuration file, a WebhookServerConfiguration of config.mutato.kubesphere.io/v1alpha1. Flags override the fields of the file.")
+	configFlags := bindConfigFlags(flag.CommandLine, cfg)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -46,39 +72,337 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	metrics.ExportOpenTelemetry()
+
+	if err := configFlags.load(flag.CommandLine, configFile, cfg); err != nil {
+		setupLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+	if errs := validateConfig(cfg); len(errs) > 0 {
+		setupLog.Error(errs.ToAggregate(), "invalid configuration")
+		os.Exit(1)
+	}
+
+	// The mutation ID is only known to the mutation system, which stamps it
+	// on objects with the applied mutators. The webhook reads the stamps for
+	// the audit annotations and drops them unless they were asked for.
+	*mutation.MutationAnnotationsEnabled = cfg.Mutation.Annotations || cfg.Mutation.AuditAnnotations
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{})
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    cfg.Webhook.Port,
+			CertDir: cfg.Webhook.CertDir,
+		}),
+		// Workspaces and workload owners are read as unstructured objects on
+		// every admission request.
+		Client:                 client.Options{Cache: &client.CacheOptions{Unstructured: true}},
+		Metrics:                metricsserver.Options{BindAddress: cfg.Metrics.BindAddress},
+		HealthProbeBindAddress: cfg.Health.ProbeBindAddress,
+		// Only the leader aggregates the status of Dynamic objects; the
+		// controllers and the webhook run on every replica.
+		LeaderElection:                cfg.LeaderElection.LeaderElect,
+		LeaderElectionID:              cfg.LeaderElection.ResourceName,
+		LeaderElectionNamespace:       util.GetNamespace(),
+		LeaderElectionReleaseOnCancel: true,
+	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	// Each replica identifies its status entries by the name of its pod.
+	podName := util.GetPodName()
+	if podName == "" {
+		podName, err = os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to determine the pod name")
+			os.Exit(1)
+		}
+	}
+
+	// The webhook is ready once the mutators existing at startup are
+	// ingested, so that objects are not admitted unmutated after a restart.
+	tracker := readiness.NewTracker(mgr.GetAPIReader())
+	if err := mgr.Add(tracker); err != nil {
+		setupLog.Error(err, "unable to add readiness tracker")
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("mutators", tracker.Check); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
 	runtime.Must(mutationsv1alpha1.AddToScheme(mgr.GetScheme()))
 
-	mSys := mutation.NewSystem(mutation.SystemOpts{})
-	events := make(chan event.GenericEvent, eventQueueSize)
+	mSys := mutation.NewSystem(mutation.SystemOpts{Reporter: mutation.NewStatsReporter()})
+	var registry *debug.Registry
+	if cfg.Debug.Enabled {
+		registry = debug.NewRegistry(mSys)
+		server := mgr.GetWebhookServer()
+		server.Register(debug.MutatorsPath, debug.Authorize(mgr.GetClient(), registry.MutatorsHandler()))
+		server.Register(debug.RegoPath, debug.Authorize(mgr.GetClient(), registry.RegoHandler()))
+	}
+	// Requeue events reach the controller of their kind.
+	dispatcher := controller.NewDispatcher(eventQueueSize)
+	if err := mgr.Add(dispatcher); err != nil {
+		setupLog.Error(err, "unable to add event dispatcher")
+		os.Exit(1)
+	}
+	events := dispatcher.Events()
+	// Bundle sources are read uncached so that Mutato does not need to
+	// watch every ConfigMap and Secret.
+	loader := bundles.NewLoader(mgr.GetAPIReader(), cfg.Bundles.Dir, events, cfg.Bundles.PollInterval.Duration)
+	if err := mgr.Add(loader); err != nil {
+		setupLog.Error(err, "unable to add bundle loader")
+		os.Exit(1)
+	}
+	// Objects mutated by Dynamic objects are validated against the OpenAPI
+	// schema of their kind.
+	validator := schemas.NewValidator(discovery.NewDiscoveryClientForConfigOrDie(mgr.GetConfig()).OpenAPIV3(), schemaRefreshInterval)
+	// Every replica writes its own status entry of each Dynamic; the leader
+	// aggregates them.
+	podStatus := status.NewPodWriter(mgr.GetClient(), podName, statusInterval)
+	if err := mgr.Add(podStatus); err != nil {
+		setupLog.Error(err, "unable to add status writer")
+		os.Exit(1)
+	}
+	if err := mgr.Add(status.NewAggregator(mgr.GetClient(), mgr.GetAPIReader(), util.GetNamespace(), statusInterval)); err != nil {
+		setupLog.Error(err, "unable to add status aggregator")
+		os.Exit(1)
+	}
+	breakers := breaker.NewBreakers(podStatus, events)
+	if err := mgr.Add(breakers); err != nil {
+		setupLog.Error(err, "unable to add circuit breakers")
+		os.Exit(1)
+	}
 	dynamic := controller.Adder{
 		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
 		Kind:           "Dynamic",
 		NewMutationObj: func() client.Object { return &mutationsv1alpha1.Dynamic{} },
 		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
 			dynamic := obj.(*mutationsv1alpha1.Dynamic)
-			return mutators.MutatorForDynamic(dynamic)
+			return mutators.MutatorForDynamic(dynamic, loader, mgr.GetClient(), validator, breakers.For(dynamic))
+		},
+		Suspended: func(obj client.Object) bool {
+			return breakers.Suspended(obj.GetName())
 		},
-		Events: events,
+		Ingested: func(obj client.Object, deleted, active bool) {
+			if deleted {
+				podStatus.Forget(obj.GetName())
+				return
+			}
+			podStatus.RecordIngestion(obj.GetName(), obj.GetGeneration(), active)
+		},
+		Events: dispatcher,
 	}
 	if err := dynamic.Add(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dynamic")
 		os.Exit(1)
 	}
 
-	if err = (&mutato.Webhook{
+	template := controller.TemplateAdder{Events: events}
+	if err := template.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DynamicTemplate")
+		os.Exit(1)
+	}
+
+	sidecarInjection := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "SidecarInjection",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.SidecarInjection{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			injection := obj.(*mutationsv1alpha1.SidecarInjection)
+			return mutators.MutatorForSidecarInjection(injection)
+		},
+		Events: dispatcher,
+	}
+	if err := sidecarInjection.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SidecarInjection")
+		os.Exit(1)
+	}
+
+	resourcePolicy := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "ResourcePolicy",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.ResourcePolicy{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			policy := obj.(*mutationsv1alpha1.ResourcePolicy)
+			return mutators.MutatorForResourcePolicy(policy)
+		},
+		Events: dispatcher,
+	}
+	if err := resourcePolicy.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ResourcePolicy")
+		os.Exit(1)
+	}
+
+	imageRewrite := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "ImageRewrite",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.ImageRewrite{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			rewrite := obj.(*mutationsv1alpha1.ImageRewrite)
+			return mutators.MutatorForImageRewrite(rewrite)
+		},
+		Events: dispatcher,
+	}
+	if err := imageRewrite.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ImageRewrite")
+		os.Exit(1)
+	}
+
+	podSecurityDefault := controller.Adder{
 		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "PodSecurityDefault",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.PodSecurityDefault{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			defaults := obj.(*mutationsv1alpha1.PodSecurityDefault)
+			return mutators.MutatorForPodSecurityDefault(defaults)
+		},
+		Events: dispatcher,
+	}
+	if err := podSecurityDefault.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PodSecurityDefault")
+		os.Exit(1)
+	}
+
+	workspaces := mutators.NewWorkspaceGetter(mgr.GetClient())
+	placement := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "Placement",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.Placement{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			placement := obj.(*mutationsv1alpha1.Placement)
+			return mutators.MutatorForPlacement(placement, workspaces)
+		},
+		Events: dispatcher,
+	}
+	if err := placement.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Placement")
+		os.Exit(1)
+	}
+
+	podPreset := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "PodPreset",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.PodPreset{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			preset := obj.(*mutationsv1alpha1.PodPreset)
+			return mutators.MutatorForPodPreset(preset)
+		},
+		Events: dispatcher,
+	}
+	if err := podPreset.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PodPreset")
+		os.Exit(1)
+	}
+
+	workloads := mutators.NewWorkloadGetter(mgr.GetClient(), mgr.GetAPIReader())
+	metadataPropagation := controller.Adder{
+		MutationSystem: mSys,
+		Tracker:        tracker,
+		Debug:          registry,
+		Kind:           "MetadataPropagation",
+		NewMutationObj: func() client.Object { return &mutationsv1alpha1.MetadataPropagation{} },
+		MutatorFor: func(obj client.Object) (mutationtypes.Mutator, error) {
+			propagation := obj.(*mutationsv1alpha1.MetadataPropagation)
+			return mutators.MutatorForMetadataPropagation(propagation, workspaces, workloads)
+		},
+		Events: dispatcher,
+	}
+	if err := metadataPropagation.Add(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MetadataPropagation")
+		os.Exit(1)
+	}
+
+	tracer, err := newTracerProvider(context.Background(), &cfg.Tracing)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	if tracer != nil {
+		if err := mgr.Add(tracer); err != nil {
+			setupLog.Error(err, "unable to add tracer provider")
+			os.Exit(1)
+		}
+	}
+
+	redactor, err := redact.New(cfg.Redaction.EnvNames)
+	if err != nil {
+		setupLog.Error(err, "invalid redaction.envNames")
+		os.Exit(1)
+	}
+
+	decisions := newDecisionLogger(&cfg.DecisionLog)
+	if decisions != nil {
+		if err := mgr.Add(decisions); err != nil {
+			setupLog.Error(err, "unable to add decision log")
+			os.Exit(1)
+		}
+	}
+
+	if err = (&mutato.Webhook{
+		MutationSystem:      mSys,
+		Status:              podStatus,
+		MutationAnnotations: cfg.Mutation.Annotations,
+		AuditAnnotations:    cfg.Mutation.AuditAnnotations,
+		Decisions:           decisions,
+		Redactor:            redactor,
+		Ready:               tracker.Ready,
+		NotReadyPolicy:      cfg.Webhook.NotReadyPolicy,
+		ServiceAccount:      cfg.Webhook.ServiceAccount,
 	}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
 		os.Exit(1)
 	}
 
+	if err = (&mutato.ValidatingWebhook{
+		Validators: map[string]mutato.Validator{
+			// Bundles and templates may be created after the Dynamic, so
+			// they are not loaded at admission.
+			dynamic.Kind: {
+				NewObj: dynamic.NewMutationObj,
+				Validate: func(obj client.Object) error {
+					return mutators.ValidateDynamic(obj.(*mutationsv1alpha1.Dynamic), mgr.GetClient())
+				},
+			},
+			"DynamicTemplate": {
+				NewObj: func() client.Object { return &mutationsv1alpha1.DynamicTemplate{} },
+				Validate: func(obj client.Object) error {
+					return mutators.ValidateDynamicTemplate(obj.(*mutationsv1alpha1.DynamicTemplate))
+				},
+			},
+			sidecarInjection.Kind:    mutato.ValidatorFor(&sidecarInjection),
+			resourcePolicy.Kind:      mutato.ValidatorFor(&resourcePolicy),
+			imageRewrite.Kind:        mutato.ValidatorFor(&imageRewrite),
+			podSecurityDefault.Kind:  mutato.ValidatorFor(&podSecurityDefault),
+			placement.Kind:           mutato.ValidatorFor(&placement),
+			podPreset.Kind:           mutato.ValidatorFor(&podPreset),
+			metadataPropagation.Kind: mutato.ValidatorFor(&metadataPropagation),
+		},
+	}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Validating")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
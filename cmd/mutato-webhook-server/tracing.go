@@ -0,0 +1,33 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	configv1alpha1 "kubesphere.io/muato/api/config/v1alpha1"
+	"kubesphere.io/muato/pkg/tracing"
+)
+
+// newTracerProvider returns the tracer provider configured by c, or nil if
+// tracing is disabled. c must be valid.
+func newTracerProvider(ctx context.Context, c *configv1alpha1.TracingConfiguration) (*tracing.Provider, error) {
+	if c.Endpoint == "" {
+		return nil, nil
+	}
+	return tracing.NewProvider(ctx, c.Endpoint, c.Insecure, c.SampleRatio)
+}
@@ -0,0 +1,269 @@
+// Package breaker suspends Dynamic rules that keep failing. A Breaker
+// observes the evaluations of one rule; when it opens, the Dynamic is
+// requeued so that its mutator is removed from the mutation system, and the
+// suspension is recorded in the status entry of the replica. After the
+// cooldown the mutator is added back and the next evaluation decides
+// whether it stays.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/metrics"
+	"kubesphere.io/muato/pkg/status"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("breaker").WithValues(logging.Process, "breaker")
+
+const (
+	defaultErrorRate      = 50
+	defaultMinEvaluations = 10
+	defaultWindow         = time.Minute
+	defaultCooldown       = time.Minute
+
+	// transitionQueueSize bounds the state changes waiting to be written.
+	transitionQueueSize = 256
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed breakers let the rule run and count its failures.
+	Closed State = iota
+	// Open breakers suspend the rule.
+	Open
+	// HalfOpen breakers let the rule run until its next evaluation.
+	HalfOpen
+)
+
+// transition is a state change to be recorded in the status of a Dynamic.
+type transition struct {
+	breaker *Breaker
+	state   State
+	message string
+}
+
+// Breakers holds the Breaker of every Dynamic with a circuit breaker.
+type Breakers struct {
+	status      *status.PodWriter
+	events      chan<- event.GenericEvent
+	transitions chan transition
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// Breakers observe the requests served by every replica.
+var _ manager.LeaderElectionRunnable = &Breakers{}
+
+// NewBreakers returns Breakers requeueing Dynamic objects through events
+// and recording suspensions with w.
+func NewBreakers(w *status.PodWriter, events chan<- event.GenericEvent) *Breakers {
+	return &Breakers{
+		status:      w,
+		events:      events,
+		transitions: make(chan transition, transitionQueueSize),
+		breakers:    map[string]*Breaker{},
+	}
+}
+
+// For returns the Breaker of dynamic, or nil if it has no circuit breaker.
+// The Breaker is reset when dynamic is recreated or its spec changes.
+func (bs *Breakers) For(dynamic *mutationsv1alpha1.Dynamic) *Breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	previous := bs.breakers[dynamic.Name]
+	if previous != nil && previous.uid == dynamic.UID && previous.generation == dynamic.Generation {
+		return previous
+	}
+	var b *Breaker
+	if config := dynamic.Spec.CircuitBreaker; config != nil {
+		b = newBreaker(bs, dynamic, config)
+		bs.breakers[dynamic.Name] = b
+	} else {
+		delete(bs.breakers, dynamic.Name)
+	}
+	if previous != nil && previous.State() != Closed && previous.uid == dynamic.UID {
+		bs.notify(transition{breaker: previous, state: Closed, message: "The rule changed"})
+	}
+	return b
+}
+
+// Suspended returns true if the mutator of the named Dynamic must not be
+// part of the mutation system.
+func (bs *Breakers) Suspended(name string) bool {
+	bs.mu.Lock()
+	b := bs.breakers[name]
+	bs.mu.Unlock()
+	return b != nil && b.State() == Open
+}
+
+// Start handles state changes until ctx is done.
+func (bs *Breakers) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case t := <-bs.transitions:
+			bs.write(t)
+		}
+	}
+}
+
+func (bs *Breakers) NeedLeaderElection() bool {
+	return false
+}
+
+// notify queues t without blocking the admission request that caused it.
+func (bs *Breakers) notify(t transition) {
+	select {
+	case bs.transitions <- t:
+	default:
+		log.Info("Dropping circuit breaker transition, queue is full", "dynamic", t.breaker.name)
+	}
+}
+
+func (bs *Breakers) write(t transition) {
+	b := t.breaker
+	if t.state == Open {
+		metrics.CircuitBreakerTrips.WithLabelValues(b.name).Inc()
+		log.Info("Suspending rule", "dynamic", b.name, "error", t.message)
+		bs.enqueue(b.name)
+		time.AfterFunc(b.cooldown, func() {
+			if b.probe() {
+				log.Info("Probing suspended rule", "dynamic", b.name)
+				bs.enqueue(b.name)
+			}
+		})
+	}
+
+	bs.status.RecordSuspension(b.name, t.state == Open, t.message)
+}
+
+// enqueue requeues the named Dynamic.
+func (bs *Breakers) enqueue(name string) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(mutationsv1alpha1.GroupVersion.WithKind("Dynamic"))
+	u.SetName(name)
+	bs.events <- event.GenericEvent{Object: u}
+}
+
+// Breaker counts the failed evaluations of one Dynamic rule.
+type Breaker struct {
+	owner      *Breakers
+	name       string
+	uid        apitypes.UID
+	generation int64
+
+	errorRate      int32
+	minEvaluations int32
+	window         time.Duration
+	cooldown       time.Duration
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	evaluations int32
+	failures    int32
+}
+
+func newBreaker(owner *Breakers, dynamic *mutationsv1alpha1.Dynamic, config *mutationsv1alpha1.CircuitBreaker) *Breaker {
+	b := &Breaker{
+		owner:          owner,
+		name:           dynamic.Name,
+		uid:            dynamic.UID,
+		generation:     dynamic.Generation,
+		errorRate:      config.ErrorRate,
+		minEvaluations: config.MinEvaluations,
+		window:         config.Window.Duration,
+		cooldown:       config.Cooldown.Duration,
+	}
+	if b.errorRate <= 0 {
+		b.errorRate = defaultErrorRate
+	}
+	if b.minEvaluations <= 0 {
+		b.minEvaluations = defaultMinEvaluations
+	}
+	if b.window <= 0 {
+		b.window = defaultWindow
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultCooldown
+	}
+	return b
+}
+
+// State returns the state of b.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Record records the result of an evaluation of the rule. It accepts a nil
+// receiver.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		// The mutator is evaluated until it is removed from the system.
+		return
+	case HalfOpen:
+		if err != nil {
+			b.open(err)
+			return
+		}
+		b.state = Closed
+		b.reset(time.Now())
+		b.owner.notify(transition{breaker: b, state: Closed, message: "The rule succeeded after its cooldown"})
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(b.windowStart) >= b.window {
+		b.reset(now)
+	}
+	b.evaluations++
+	if err == nil {
+		return
+	}
+	b.failures++
+	if b.evaluations >= b.minEvaluations && b.failures*100 >= b.errorRate*b.evaluations {
+		b.open(err)
+	}
+}
+
+func (b *Breaker) open(err error) {
+	b.state = Open
+	b.owner.notify(transition{breaker: b, state: Open, message: err.Error()})
+}
+
+func (b *Breaker) reset(now time.Time) {
+	b.windowStart, b.evaluations, b.failures = now, 0, 0
+}
+
+// probe moves an open breaker to half open and returns true, unless it was
+// reset meanwhile.
+func (b *Breaker) probe() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != Open {
+		return false
+	}
+	b.state = HalfOpen
+	return true
+}
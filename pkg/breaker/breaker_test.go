@@ -0,0 +1,158 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+var errRule = errors.New("rule failed")
+
+func newTestBreaker(config *mutationsv1alpha1.CircuitBreaker) (*Breakers, *Breaker) {
+	bs := NewBreakers(nil, nil)
+	dynamic := &mutationsv1alpha1.Dynamic{
+		ObjectMeta: metav1.ObjectMeta{Name: "rule", UID: "uid", Generation: 1},
+		Spec:       mutationsv1alpha1.DynamicSpec{CircuitBreaker: config},
+	}
+	return bs, bs.For(dynamic)
+}
+
+// transitions returns the states of the queued transitions.
+func transitions(bs *Breakers) []State {
+	var states []State
+	for {
+		select {
+		case t := <-bs.transitions:
+			states = append(states, t.state)
+		default:
+			return states
+		}
+	}
+}
+
+func TestBreakerRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  mutationsv1alpha1.CircuitBreaker
+		results []error
+		want    State
+	}{{
+		name:    "no failures",
+		config:  mutationsv1alpha1.CircuitBreaker{MinEvaluations: 2},
+		results: []error{nil, nil, nil},
+		want:    Closed,
+	}, {
+		name:    "below min evaluations",
+		config:  mutationsv1alpha1.CircuitBreaker{MinEvaluations: 3},
+		results: []error{errRule, errRule},
+		want:    Closed,
+	}, {
+		name:    "error rate reached",
+		config:  mutationsv1alpha1.CircuitBreaker{MinEvaluations: 4, ErrorRate: 50},
+		results: []error{nil, errRule, nil, errRule},
+		want:    Open,
+	}, {
+		name:    "error rate not reached",
+		config:  mutationsv1alpha1.CircuitBreaker{MinEvaluations: 4, ErrorRate: 75},
+		results: []error{nil, errRule, nil, errRule},
+		want:    Closed,
+	}, {
+		name:    "defaults",
+		config:  mutationsv1alpha1.CircuitBreaker{},
+		results: []error{errRule, errRule, errRule, errRule, errRule, errRule, errRule, errRule, errRule, errRule},
+		want:    Open,
+	}, {
+		name:    "open ignores results",
+		config:  mutationsv1alpha1.CircuitBreaker{MinEvaluations: 1},
+		results: []error{errRule, nil, nil},
+		want:    Open,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs, b := newTestBreaker(&tt.config)
+			for _, err := range tt.results {
+				b.Record(err)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %v, want %v", got, tt.want)
+			}
+			var want []State
+			if tt.want == Open {
+				want = []State{Open}
+			}
+			if got := transitions(bs); !cmp.Equal(got, want) {
+				t.Errorf("transitions = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	_, b := newTestBreaker(&mutationsv1alpha1.CircuitBreaker{
+		MinEvaluations: 2,
+		Window:         metav1.Duration{Duration: time.Minute},
+	})
+	b.Record(errRule)
+	// Failures of an expired window are forgotten.
+	b.windowStart = b.windowStart.Add(-time.Minute)
+	b.Record(errRule)
+	if got := b.State(); got != Closed {
+		t.Errorf("State() = %v, want %v", got, Closed)
+	}
+	b.Record(errRule)
+	if got := b.State(); got != Open {
+		t.Errorf("State() = %v, want %v", got, Open)
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		result error
+		want   State
+		// wantTransitions are queued after the breaker opened.
+		wantTransitions []State
+	}{{
+		name:            "probe succeeds",
+		result:          nil,
+		want:            Closed,
+		wantTransitions: []State{Closed},
+	}, {
+		name:            "probe fails",
+		result:          errRule,
+		want:            Open,
+		wantTransitions: []State{Open},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs, b := newTestBreaker(&mutationsv1alpha1.CircuitBreaker{MinEvaluations: 1})
+			if b.probe() {
+				t.Fatal("probe() of a closed breaker = true, want false")
+			}
+			b.Record(errRule)
+			transitions(bs)
+			if !b.probe() {
+				t.Fatal("probe() of an open breaker = false, want true")
+			}
+			if got := b.State(); got != HalfOpen {
+				t.Fatalf("State() = %v, want %v", got, HalfOpen)
+			}
+			b.Record(tt.result)
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %v, want %v", got, tt.want)
+			}
+			if got := transitions(bs); !cmp.Equal(got, tt.wantTransitions) {
+				t.Errorf("transitions = %v, want %v", got, tt.wantTransitions)
+			}
+		})
+	}
+}
+
+func TestNilBreakerRecord(t *testing.T) {
+	var b *Breaker
+	b.Record(errRule)
+}
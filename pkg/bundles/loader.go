@@ -0,0 +1,331 @@
+package bundles
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/keys"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("bundles").WithValues(logging.Process, "bundles")
+
+const (
+	// DefaultKey is the ConfigMap or Secret entry holding the bundle.
+	DefaultKey = "bundle.tar.gz"
+	// DefaultAlgorithm is the algorithm of verification keys.
+	DefaultAlgorithm = "RS256"
+)
+
+// Loader loads the OPA bundles referenced by Dynamic objects and requeues
+// a Dynamic on events when its bundle changes.
+type Loader struct {
+	reader   client.Reader
+	dir      string
+	events   chan<- event.GenericEvent
+	interval time.Duration
+
+	mu sync.Mutex
+	// revisions holds the revision of the source last read for each
+	// Dynamic, or "" if its source could not be read, e.g. because the
+	// Dynamic was created before its ConfigMap or Secret.
+	revisions map[types.NamespacedName]string
+}
+
+// Loader polls bundle sources on every replica.
+var _ manager.LeaderElectionRunnable = &Loader{}
+
+// NewLoader returns a Loader reading ConfigMaps and Secrets with reader and
+// bundle paths below dir. Sources are checked for changes every interval.
+func NewLoader(reader client.Reader, dir string, events chan<- event.GenericEvent, interval time.Duration) *Loader {
+	return &Loader{
+		reader:    reader,
+		dir:       dir,
+		events:    events,
+		interval:  interval,
+		revisions: map[types.NamespacedName]string{},
+	}
+}
+
+// source is the content of a bundle source.
+type source struct {
+	// tarball holds a bundle tarball; otherwise the bundle is the
+	// directory at path.
+	tarball  []byte
+	path     string
+	keys     map[string]*keys.Config
+	revision string
+}
+
+// Load reads, verifies and parses the bundle of dynamic. It returns the
+// bundle and its revision, which changes whenever the source or the
+// verification keys change. The source is polled even if loading fails, so
+// that dynamic is requeued once it is fixed.
+func (l *Loader) Load(ctx context.Context, dynamic *mutationsv1alpha1.Dynamic) (*bundle.Bundle, string, error) {
+	spec := dynamic.Spec.Bundle
+	name := types.NamespacedName{Namespace: dynamic.Namespace, Name: dynamic.Name}
+	src, err := l.fetch(ctx, spec)
+	if err != nil {
+		l.record(name, "")
+		return nil, "", err
+	}
+	l.record(name, src.revision)
+
+	var loader bundle.DirectoryLoader
+	if src.tarball != nil {
+		loader = bundle.NewTarballLoaderWithBaseURL(bytes.NewReader(src.tarball), "")
+	} else {
+		loader = bundle.NewDirectoryLoader(src.path)
+	}
+	reader := bundle.NewCustomReader(loader).WithBundleName(dynamic.Name)
+	if v := spec.Verification; v != nil {
+		reader = reader.WithBundleVerificationConfig(bundle.NewVerificationConfig(src.keys, v.KeyID, v.Scope, v.Exclude))
+	}
+	b, err := reader.Read()
+	if err != nil {
+		return nil, "", fmt.Errorf("reading bundle: %w", err)
+	}
+	// The reader only verifies signatures that are present.
+	if spec.Verification != nil && len(b.Signatures.Signatures) == 0 {
+		return nil, "", fmt.Errorf("reading bundle: bundle is not signed")
+	}
+
+	log.V(1).Info("Loaded bundle", "dynamic", dynamic.Name, "revision", src.revision)
+	return &b, src.revision, nil
+}
+
+// fetch reads the bundle source and the verification keys of spec.
+func (l *Loader) fetch(ctx context.Context, spec *mutationsv1alpha1.BundleSource) (*source, error) {
+	hash := sha256.New()
+	src := &source{}
+	switch {
+	case spec.ConfigMap != nil:
+		ref := spec.ConfigMap
+		cm := &corev1.ConfigMap{}
+		if err := l.reader.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, cm); err != nil {
+			return nil, fmt.Errorf("getting ConfigMap %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		key := keyOrDefault(ref.Key)
+		if data, ok := cm.BinaryData[key]; ok {
+			src.tarball = data
+		} else if data, ok := cm.Data[key]; ok {
+			src.tarball = []byte(data)
+		} else {
+			return nil, fmt.Errorf("ConfigMap %s/%s has no key %q", ref.Namespace, ref.Name, key)
+		}
+		hash.Write(src.tarball)
+	case spec.Secret != nil:
+		ref := spec.Secret
+		secret := &corev1.Secret{}
+		if err := l.reader.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("getting Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		key := keyOrDefault(ref.Key)
+		data, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("Secret %s/%s has no key %q", ref.Namespace, ref.Name, key)
+		}
+		src.tarball = data
+		hash.Write(src.tarball)
+	case spec.Path != "":
+		dir, err := l.resolve(spec.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := hashDir(hash, dir); err != nil {
+			return nil, err
+		}
+		if isOCILayout(dir) {
+			if src.tarball, err = readOCILayout(dir); err != nil {
+				return nil, fmt.Errorf("reading OCI layout %q: %w", spec.Path, err)
+			}
+		} else {
+			src.path = dir
+		}
+	default:
+		return nil, fmt.Errorf("bundle source has no configMap, secret or path")
+	}
+
+	if v := spec.Verification; v != nil {
+		var err error
+		if src.keys, err = l.fetchKeys(ctx, v); err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(src.keys))
+		for id := range src.keys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(hash, "\x00%s\x00%s", id, src.keys[id].Key)
+		}
+	}
+	src.revision = hex.EncodeToString(hash.Sum(nil))
+	return src, nil
+}
+
+func (l *Loader) fetchKeys(ctx context.Context, v *mutationsv1alpha1.BundleVerification) (map[string]*keys.Config, error) {
+	algorithm := v.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	if !keys.IsSupportedAlgorithm(algorithm) {
+		return nil, fmt.Errorf("unsupported verification algorithm %q", algorithm)
+	}
+	ref := v.KeysSecret
+	secret := &corev1.Secret{}
+	if err := l.reader.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("getting verification keys Secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	if len(secret.Data) == 0 {
+		return nil, fmt.Errorf("verification keys Secret %s/%s is empty", ref.Namespace, ref.Name)
+	}
+	result := make(map[string]*keys.Config, len(secret.Data))
+	for id, key := range secret.Data {
+		result[id] = &keys.Config{Key: string(key), Algorithm: algorithm, Scope: v.Scope}
+	}
+	if v.KeyID != "" && result[v.KeyID] == nil {
+		return nil, fmt.Errorf("verification key %q not found in Secret %s/%s", v.KeyID, ref.Namespace, ref.Name)
+	}
+	return result, nil
+}
+
+// resolve returns the directory for a bundle path, which must not escape
+// the bundle directory.
+func (l *Loader) resolve(path string) (string, error) {
+	if l.dir == "" {
+		return "", fmt.Errorf("bundle paths are disabled")
+	}
+	dir := filepath.Join(l.dir, filepath.FromSlash(path))
+	if rel, err := filepath.Rel(l.dir, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("bundle path %q is outside of the bundle directory", path)
+	}
+	return dir, nil
+}
+
+// Start polls the sources of loaded bundles and requeues the Dynamic
+// objects whose bundle changed, until ctx is done.
+func (l *Loader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			l.poll(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection returns false as every replica serves mutations.
+func (l *Loader) NeedLeaderElection() bool {
+	return false
+}
+
+func (l *Loader) poll(ctx context.Context) {
+	l.mu.Lock()
+	revisions := make(map[types.NamespacedName]string, len(l.revisions))
+	for name, revision := range l.revisions {
+		revisions[name] = revision
+	}
+	l.mu.Unlock()
+
+	for name, revision := range revisions {
+		dynamic := &mutationsv1alpha1.Dynamic{}
+		err := l.reader.Get(ctx, name, dynamic)
+		if apierrors.IsNotFound(err) || err == nil && dynamic.Spec.Bundle == nil {
+			l.forget(name, revision)
+			continue
+		}
+		if err != nil {
+			log.Error(err, "Failed to get Dynamic", "dynamic", name)
+			continue
+		}
+		src, err := l.fetch(ctx, dynamic.Spec.Bundle)
+		if err != nil {
+			// Keep serving the loaded bundle, the error surfaces once the
+			// Dynamic is reconciled for another reason. A source that was
+			// never read is expected to be missing until it is created.
+			if revision != "" {
+				log.Error(err, "Failed to check bundle", "dynamic", name)
+			}
+			continue
+		}
+		if src.revision == revision {
+			continue
+		}
+		log.Info("Bundle changed", "dynamic", name, "revision", src.revision)
+		dynamic.SetGroupVersionKind(mutationsv1alpha1.GroupVersion.WithKind("Dynamic"))
+		select {
+		case l.events <- event.GenericEvent{Object: dynamic}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// record sets the revision of the source of name.
+func (l *Loader) record(name types.NamespacedName, revision string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revisions[name] = revision
+}
+
+// forget stops polling name unless it was reloaded in the meantime.
+func (l *Loader) forget(name types.NamespacedName, revision string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.revisions[name] == revision {
+		delete(l.revisions, name)
+	}
+}
+
+func keyOrDefault(key string) string {
+	if key == "" {
+		return DefaultKey
+	}
+	return key
+}
+
+// hashDir writes the relative paths and contents of the regular files
+// below dir to w.
+func hashDir(w io.Writer, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(w, "\x00%s\x00", filepath.ToSlash(rel))
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
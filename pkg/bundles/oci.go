@@ -0,0 +1,97 @@
+package bundles
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	ociLayoutFile = "oci-layout"
+	ociIndexFile  = "index.json"
+
+	// bundleLayerMediaType is the media type of the bundle layer in images
+	// pushed by `opa build` and its OCI tooling.
+	bundleLayerMediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+var digestPattern = regexp.MustCompile(`^(sha256|sha512):([a-f0-9]+)$`)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// isOCILayout returns true if dir is an OCI image layout.
+func isOCILayout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ociLayoutFile))
+	return err == nil
+}
+
+// readOCILayout returns the bundle tarball of the first image in the OCI
+// image layout at dir.
+func readOCILayout(dir string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ociIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ociIndexFile, err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("%s lists no manifests", ociIndexFile)
+	}
+	if data, err = readBlob(dir, index.Manifests[0].Digest); err != nil {
+		return nil, err
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == bundleLayerMediaType {
+			return readBlob(dir, layer.Digest)
+		}
+	}
+	return nil, fmt.Errorf("manifest has no %s layer", bundleLayerMediaType)
+}
+
+// readBlob reads the blob with the given digest and verifies its content.
+func readBlob(dir, digest string) ([]byte, error) {
+	parts := digestPattern.FindStringSubmatch(strings.TrimSpace(digest))
+	if parts == nil {
+		return nil, fmt.Errorf("invalid digest %q", digest)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "blobs", parts[1], parts[2]))
+	if err != nil {
+		return nil, err
+	}
+	var sum []byte
+	switch parts[1] {
+	case "sha256":
+		s := sha256.Sum256(data)
+		sum = s[:]
+	case "sha512":
+		s := sha512.Sum512(data)
+		sum = s[:]
+	}
+	if hex.EncodeToString(sum) != parts[2] {
+		return nil, fmt.Errorf("blob %s does not match its digest", digest)
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package bundles
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/open-policy-agent/opa/keys"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// ValidateSource returns an error if spec can never be loaded. It does not
+// read the source, which may be created after the Dynamic.
+func ValidateSource(spec *mutationsv1alpha1.BundleSource) error {
+	sources := 0
+	if ref := spec.ConfigMap; ref != nil {
+		sources++
+		if ref.Namespace == "" || ref.Name == "" {
+			return fmt.Errorf("configMap requires namespace and name")
+		}
+	}
+	if ref := spec.Secret; ref != nil {
+		sources++
+		if ref.Namespace == "" || ref.Name == "" {
+			return fmt.Errorf("secret requires namespace and name")
+		}
+	}
+	if spec.Path != "" {
+		sources++
+		if !filepath.IsLocal(filepath.FromSlash(spec.Path)) {
+			return fmt.Errorf("path %q must be relative and below the bundle directory", spec.Path)
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of configMap, secret and path must be set")
+	}
+
+	if v := spec.Verification; v != nil {
+		if v.KeysSecret.Namespace == "" || v.KeysSecret.Name == "" {
+			return fmt.Errorf("verification keysSecret requires namespace and name")
+		}
+		if v.Algorithm != "" && !keys.IsSupportedAlgorithm(v.Algorithm) {
+			return fmt.Errorf("unsupported verification algorithm %q", v.Algorithm)
+		}
+		for _, pattern := range v.Exclude {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("verification exclude %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
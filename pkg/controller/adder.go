@@ -1,19 +1,19 @@
 package controller
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation"
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
-	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"kubesphere.io/muato/pkg/debug"
+	"kubesphere.io/muato/pkg/readiness"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
@@ -30,25 +30,50 @@ type Adder struct {
 	// turns it into a mutator. The contents of the mutation object
 	// are set by the API server.
 	MutatorFor func(client.Object) (types.Mutator, error)
-	// Events enables queueing other Mutators for updates.
-	Events chan event.GenericEvent
-	// EventsSource watches for events broadcast to Events.
-	// If multiple controllers listen to EventsSource, then
-	// each controller gets a copy of each event.
-	EventsSource source.Source
+	// Suspended, if set, reports whether the mutator of an object must be
+	// kept out of the mutation system for now.
+	Suspended func(client.Object) bool
+	// Ingested, if set, is told whether the mutator of each reconciled
+	// object is part of the mutation system.
+	Ingested func(obj client.Object, deleted, active bool)
+	// Tracker, if set, is told about the reconciled objects so that the
+	// webhook waits for the mutators existing at startup.
+	Tracker *readiness.Tracker
+	// Debug, if set, records the state of the mutators for the debug
+	// endpoints.
+	Debug *debug.Registry
+	// Events, if set, enables queueing other Mutators for updates and
+	// requeues the objects of Kind sent to it.
+	Events *Dispatcher
 }
 
 // Add creates a new Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func (a *Adder) Add(mgr manager.Manager) error {
-	r := newReconciler(mgr, a.MutationSystem, a.Kind, a.NewMutationObj, a.MutatorFor, a.Events)
+	var events chan<- event.GenericEvent
+	if a.Events != nil {
+		events = a.Events.Events()
+	}
+	r := newReconciler(mgr, a.MutationSystem, a.Kind, a.NewMutationObj, a.MutatorFor, events)
+	r.suspended = a.Suspended
+	r.tracker = a.Tracker
+	r.ingested = a.Ingested
+	r.debug = a.Debug
+	if a.Tracker != nil {
+		a.Tracker.Expect(r.gvk)
+	}
 	return a.add(mgr, r)
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler.
 func (a *Adder) add(mgr manager.Manager, r *Reconciler) error {
 	// Create a new controller
-	c, err := controller.New(fmt.Sprintf("%s-controller", strings.ToLower(r.gvk.Kind)), mgr, controller.Options{Reconciler: r})
+	// Every replica keeps its own mutation system, so the controllers run
+	// on every replica rather than on the leader only.
+	c, err := controller.New(fmt.Sprintf("%s-controller", strings.ToLower(r.gvk.Kind)), mgr, controller.Options{
+		Reconciler:         r,
+		NeedLeaderElection: ptr.To(false),
+	})
 	if err != nil {
 		return err
 	}
@@ -61,21 +86,9 @@ func (a *Adder) add(mgr manager.Manager, r *Reconciler) error {
 		return err
 	}
 
-	if a.EventsSource != nil {
+	if a.Events != nil {
 		// Watch for enqueued events.
-		err = c.Watch(
-			source.Channel(a.Events,
-				handler.TypedEnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
-					if obj.GetObjectKind().GroupVersionKind().Kind != r.gvk.Kind {
-						return nil
-					}
-					return []reconcile.Request{{
-						NamespacedName: apitypes.NamespacedName{
-							Namespace: obj.GetNamespace(),
-							Name:      obj.GetName(),
-						},
-					}}
-				})))
+		err = c.Watch(source.Channel(a.Events.channel(r.gvk.Kind), &handler.EnqueueRequestForObject{}))
 	}
 
 	return err
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Dispatcher forwards the events requeueing mutation objects to the
+// controller of their kind. A channel source hands each event to a single
+// watch, so every controller watches a channel of its own.
+type Dispatcher struct {
+	events chan event.GenericEvent
+	size   int
+
+	mu    sync.Mutex
+	kinds map[string]chan event.GenericEvent
+}
+
+// The controllers run on every replica.
+var _ manager.LeaderElectionRunnable = &Dispatcher{}
+
+// NewDispatcher returns a Dispatcher buffering size events in total and per
+// kind.
+func NewDispatcher(size int) *Dispatcher {
+	return &Dispatcher{
+		events: make(chan event.GenericEvent, size),
+		size:   size,
+		kinds:  map[string]chan event.GenericEvent{},
+	}
+}
+
+// Events returns the channel to send events to. The kind of each event's
+// object must be set.
+func (d *Dispatcher) Events() chan<- event.GenericEvent {
+	return d.events
+}
+
+// channel returns the channel of the events for kind.
+func (d *Dispatcher) channel(kind string) <-chan event.GenericEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch, ok := d.kinds[kind]
+	if !ok {
+		ch = make(chan event.GenericEvent, d.size)
+		d.kinds[kind] = ch
+	}
+	return ch
+}
+
+// Start forwards events until ctx is done. Events for kinds without a
+// controller are dropped.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	log := logf.Log.WithName("controller").WithValues(logging.Process, "event-dispatcher")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-d.events:
+			kind := e.Object.GetObjectKind().GroupVersionKind().Kind
+			d.mu.Lock()
+			ch, ok := d.kinds[kind]
+			d.mu.Unlock()
+			if !ok {
+				log.Info("Dropping event for kind without a controller", "kind", kind, "name", e.Object.GetName())
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case ch <- e:
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false as every replica runs the controllers.
+func (d *Dispatcher) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher(4)
+	dynamic := d.channel("Dynamic")
+	policy := d.channel("ResourcePolicy")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = d.Start(ctx) }()
+
+	send := func(kind, name string) {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: "mutations.kubesphere.io", Kind: kind})
+		u.SetName(name)
+		d.Events() <- event.GenericEvent{Object: u}
+	}
+	send("Unknown", "dropped")
+	send("ResourcePolicy", "policy")
+	send("Dynamic", "dynamic")
+
+	for ch, want := range map[<-chan event.GenericEvent]string{dynamic: "dynamic", policy: "policy"} {
+		select {
+		case e := <-ch:
+			if e.Object.GetName() != want {
+				t.Errorf("event for %q, want %q", e.Object.GetName(), want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event for %q", want)
+		}
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"k8s.io/client-go/tools/record"
 	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/debug"
+	"kubesphere.io/muato/pkg/metrics"
+	"kubesphere.io/muato/pkg/readiness"
+	"kubesphere.io/muato/pkg/tracing"
 	"strings"
 	"time"
 
@@ -27,6 +31,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// The reconcilers of all kinds share the stats reporter and the cache, so
+// that the reported mutator counts cover every kind.
+var (
+	statsReporter = ctrlmutators.NewStatsReporter()
+	mutationCache = ctrlmutators.NewMutationCache()
+)
+
 // newReconciler returns a new reconcile.Reconciler.
 func newReconciler(
 	mgr manager.Manager,
@@ -34,17 +45,18 @@ func newReconciler(
 	kind string,
 	newMutationObj func() client.Object,
 	mutatorFor func(client.Object) (types.Mutator, error),
-	events chan event.GenericEvent,
+	events chan<- event.GenericEvent,
 ) *Reconciler {
 	r := &Reconciler{
 		system:         mutationSystem,
 		Client:         mgr.GetClient(),
 		scheme:         mgr.GetScheme(),
-		reporter:       ctrlmutators.NewStatsReporter(),
-		cache:          ctrlmutators.NewMutationCache(),
+		reporter:       statsReporter,
+		cache:          mutationCache,
 		gvk:            mutationsv1alpha1.GroupVersion.WithKind(kind),
 		newMutationObj: newMutationObj,
 		mutatorFor:     mutatorFor,
+		recorder:       mgr.GetEventRecorderFor(fmt.Sprintf("%s-controller", strings.ToLower(kind))),
 		log:            logf.Log.WithName("controller").WithValues(logging.Process, fmt.Sprintf("%s-controller", strings.ToLower(kind))),
 		events:         events,
 	}
@@ -57,6 +69,10 @@ type Reconciler struct {
 	gvk            schema.GroupVersionKind
 	newMutationObj func() client.Object
 	mutatorFor     func(client.Object) (types.Mutator, error)
+	suspended      func(client.Object) bool
+	tracker        *readiness.Tracker
+	ingested       func(obj client.Object, deleted, active bool)
+	debug          *debug.Registry
 
 	system   *mutation.System
 	scheme   *runtime.Scheme
@@ -65,7 +81,7 @@ type Reconciler struct {
 	log      logr.Logger
 	recorder record.EventRecorder
 
-	events chan event.GenericEvent
+	events chan<- event.GenericEvent
 }
 
 // +kubebuilder:rbac:groups=mutations.gatekeeper.sh,resources=*,verbs=get;list;watch;create;update;patch;delete
@@ -94,6 +110,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 			r.cache.Upsert(id, ingestionStatus, conflict)
 		}
 		r.reportMutator(id, ingestionStatus, startTime, deleted)
+		if r.ingested != nil {
+			r.ingested(mutationObj, deleted, ingestionStatus == ctrlmutators.MutatorStatusActive)
+		}
 	}()
 
 	// previousConflicts records the conflicts this Mutator has with other mutators
@@ -109,6 +128,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	r.tracker.Observe(r.gvk.Kind, request.NamespacedName, mutationObj.GetGeneration(), deleted)
 
 	newConflicts := r.system.GetConflicts(id)
 
@@ -138,11 +158,20 @@ func (r *Reconciler) reconcileUpsert(ctx context.Context, id types.ID, obj clien
 		r.log.Error(err, "Creating mutator for resource failed", "resource",
 			client.ObjectKeyFromObject(obj))
 		r.recorder.Eventf(obj, corev1.EventTypeWarning, "Failed", "Creating mutator for resource failed: %v", err)
+		r.debug.Observe(obj, nil, err)
 		return nil
 	}
 
-	if errToUpsert := r.system.Upsert(mutator); errToUpsert != nil {
-		r.log.Error(err, "Insert failed", "resource",
+	if r.suspended != nil && r.suspended(obj) {
+		r.log.Info("Mutator is suspended", "resource", client.ObjectKeyFromObject(obj))
+		r.debug.Observe(obj, mutator, nil)
+		return r.system.Remove(id)
+	}
+
+	errToUpsert := r.system.Upsert(r.debug.Mutator(tracing.Mutator(mutator)))
+	r.debug.Observe(obj, mutator, errToUpsert)
+	if errToUpsert != nil {
+		r.log.Error(errToUpsert, "Insert failed", "resource",
 			client.ObjectKeyFromObject(obj))
 		r.recorder.Eventf(obj, corev1.EventTypeWarning, "Failed", "Insert failed: %v", errToUpsert)
 		return nil
@@ -158,6 +187,7 @@ func (r *Reconciler) reportMutator(_ types.ID, ingestionStatus ctrlmutators.Muta
 	}
 
 	if !deleted {
+		metrics.MutatorIngestions.WithLabelValues(r.gvk.Kind, string(ingestionStatus)).Inc()
 		if err := r.reporter.ReportMutatorIngestionRequest(ingestionStatus, time.Since(startTime)); err != nil {
 			r.log.Error(err, "failed to report mutator ingestion request")
 		}
@@ -198,6 +228,7 @@ func (r *Reconciler) getOrDefault(ctx context.Context, namespacedName apiTypes.N
 // reconcileDeleted removes the Mutator from the controller and deletes the corresponding PodStatus.
 func (r *Reconciler) reconcileDeleted(ctx context.Context, id types.ID) error {
 	r.cache.Remove(id)
+	r.debug.Forget(id)
 
 	if err := r.system.Remove(id); err != nil {
 		r.log.Error(err, "Remove failed", "resource",
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/mutators"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const templateControllerName = "dynamictemplate-controller"
+
+// TemplateAdder adds the controller that validates DynamicTemplate objects
+// and requeues the Dynamic objects instantiating them when they change.
+type TemplateAdder struct {
+	// Events requeues the Dynamic objects instantiating a changed
+	// template.
+	Events chan<- event.GenericEvent
+}
+
+// Add creates a new Controller and adds it to the Manager.
+func (a *TemplateAdder) Add(mgr manager.Manager) error {
+	r := &TemplateReconciler{
+		Client:   mgr.GetClient(),
+		recorder: mgr.GetEventRecorderFor(templateControllerName),
+		log:      logf.Log.WithName("controller").WithValues(logging.Process, templateControllerName),
+		events:   a.Events,
+	}
+	// The Dynamic controller of every replica must see the requeued
+	// instances.
+	c, err := controller.New(templateControllerName, mgr, controller.Options{
+		Reconciler:         r,
+		NeedLeaderElection: ptr.To(false),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(source.Kind(mgr.GetCache(), client.Object(&mutationsv1alpha1.DynamicTemplate{}), &handler.EnqueueRequestForObject{}))
+}
+
+// TemplateReconciler reconciles DynamicTemplate objects.
+type TemplateReconciler struct {
+	client.Client
+	recorder record.EventRecorder
+	log      logr.Logger
+
+	events chan<- event.GenericEvent
+}
+
+// Reconcile validates a DynamicTemplate and requeues its instances.
+func (r *TemplateReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	r.log.Info("Reconcile", "request", request)
+
+	template := &mutationsv1alpha1.DynamicTemplate{}
+	err := r.Get(ctx, request.NamespacedName, template)
+	switch {
+	case err == nil:
+		if err := mutators.ValidateDynamicTemplate(template); err != nil {
+			r.log.Error(err, "Invalid template", "template", request.Name)
+			r.recorder.Eventf(template, corev1.EventTypeWarning, "Invalid", "Invalid template: %v", err)
+		}
+	case apierrors.IsNotFound(err):
+	default:
+		return reconcile.Result{}, err
+	}
+
+	// Instances are requeued even if the template is invalid or gone, so
+	// that they report the error.
+	dynamics := &mutationsv1alpha1.DynamicList{}
+	if err := r.List(ctx, dynamics); err != nil {
+		return reconcile.Result{}, err
+	}
+	for i := range dynamics.Items {
+		dynamic := &dynamics.Items[i]
+		if dynamic.Spec.TemplateRef != request.Name {
+			continue
+		}
+		dynamic.SetGroupVersionKind(mutationsv1alpha1.GroupVersion.WithKind("Dynamic"))
+		r.events <- event.GenericEvent{Object: dynamic}
+	}
+	return reconcile.Result{}, nil
+}
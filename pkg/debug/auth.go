@@ -0,0 +1,63 @@
+package debug
+
+import (
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Authorize serves h only to requests bearing a token of a user allowed to
+// get the path of the request as a non-resource URL, like the debug
+// endpoints of the API server. Tokens are checked with TokenReviews and
+// permissions with SubjectAccessReviews.
+func Authorize(c client.Client, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "a bearer token is required", http.StatusUnauthorized)
+			return
+		}
+		ctx := req.Context()
+
+		review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+		if err := c.Create(ctx, review); err != nil {
+			log.Error(err, "Failed to review token")
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		if !review.Status.Authenticated {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		user := review.Status.User
+		extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+		for key, value := range user.Extra {
+			extra[key] = authorizationv1.ExtraValue(value)
+		}
+		access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: req.URL.Path,
+				Verb: strings.ToLower(req.Method),
+			},
+		}}
+		if err := c.Create(ctx, access); err != nil {
+			log.Error(err, "Failed to review access", "user", user.Username)
+			http.Error(w, "failed to authorize", http.StatusInternalServerError)
+			return
+		}
+		if !access.Status.Allowed {
+			log.Info("Denied debug request", "user", user.Username, "path", req.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
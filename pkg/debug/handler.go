@@ -0,0 +1,76 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/opa/format"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+const (
+	// MutatorsPath lists the mutators as JSON.
+	MutatorsPath = "/debug/mutators"
+	// RegoPath returns the Rego modules of the Dynamic named by the name
+	// query parameter.
+	RegoPath = "/debug/rego"
+)
+
+// MutatorsHandler serves the state of the mutators.
+func (r *Registry) MutatorsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(r.Mutators()); err != nil {
+			log.Error(err, "Failed to write mutators")
+		}
+	})
+}
+
+// RegoHandler serves the Rego modules of a Dynamic, formatted, as they were
+// loaded by its last built mutator.
+func (r *Registry) RegoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := req.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "the name query parameter is required", http.StatusBadRequest)
+			return
+		}
+		id := types.ID{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "Dynamic", Name: name}
+		modules, ok := r.Modules(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no compiled Dynamic %q", name), http.StatusNotFound)
+			return
+		}
+		files := make([]string, 0, len(modules))
+		for file := range modules {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, file := range files {
+			source, err := format.Ast(modules[file])
+			if err != nil {
+				// The module was parsed, so it can always be printed.
+				source = []byte(modules[file].String() + "\n")
+			}
+			if _, err := fmt.Fprintf(w, "# %s\n%s\n", file, source); err != nil {
+				log.Error(err, "Failed to write Rego modules", "dynamic", name)
+				return
+			}
+		}
+	})
+}
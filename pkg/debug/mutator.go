@@ -0,0 +1,47 @@
+package debug
+
+import (
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+)
+
+// mutator counts the Matches and Mutate calls of the mutator it wraps.
+type mutator struct {
+	types.Mutator
+	counters *counters
+}
+
+func (m *mutator) Matches(mutable *types.Mutable) (bool, error) {
+	matches, err := m.Mutator.Matches(mutable)
+	if matches {
+		m.counters.matches.Add(1)
+	}
+	return matches, err
+}
+
+func (m *mutator) Mutate(mutable *types.Mutable) (bool, error) {
+	mutated, err := m.Mutator.Mutate(mutable)
+	m.counters.evaluations.Add(1)
+	if mutated {
+		m.counters.mutations.Add(1)
+	}
+	if err != nil {
+		m.counters.errors.Add(1)
+		m.counters.mu.Lock()
+		m.counters.err, m.counters.errTime = err.Error(), time.Now()
+		m.counters.mu.Unlock()
+	}
+	return mutated, err
+}
+
+func (m *mutator) HasDiff(other types.Mutator) bool {
+	if counted, ok := other.(*mutator); ok {
+		other = counted.Mutator
+	}
+	return m.Mutator.HasDiff(other)
+}
+
+func (m *mutator) DeepCopy() types.Mutator {
+	return &mutator{Mutator: m.Mutator.DeepCopy(), counters: m.counters}
+}
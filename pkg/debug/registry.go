@@ -0,0 +1,287 @@
+// Package debug serves the state of the mutators of a replica, so that a
+// rule that "does nothing" can be traced to what the replica actually
+// loaded: whether it compiled, whether it is suspended or in conflict, what
+// it matches and how often it ran.
+package debug
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/opa/ast"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("debug").WithValues(logging.Process, "debug")
+
+// Mutator states, as listed by the debug endpoint.
+const (
+	// StateActive mutators are evaluated.
+	StateActive = "Active"
+	// StateConflict mutators are evaluated, but conflict with others.
+	StateConflict = "Conflict"
+	// StateFailed mutators could not be built or ingested. An earlier
+	// generation may still be evaluated.
+	StateFailed = "Failed"
+	// StateSuspended mutators are kept out of the mutation system, e.g. by
+	// their circuit breaker.
+	StateSuspended = "Suspended"
+)
+
+// moduleSource is implemented by mutators evaluating Rego.
+type moduleSource interface {
+	Modules() map[string]*ast.Module
+}
+
+// Registry records the mutators reconciled by this replica. A nil Registry
+// records nothing.
+type Registry struct {
+	system *mutation.System
+
+	mu      sync.RWMutex
+	entries map[types.ID]*entry
+}
+
+type entry struct {
+	generation int64
+	match      interface{}
+	// mutator is the mutator last built for the object, unwrapped, or nil
+	// if building it failed.
+	mutator types.Mutator
+	err     string
+	errTime time.Time
+	// counters survive new generations of the object.
+	counters *counters
+}
+
+// counters count the calls of a mutator by the mutation system.
+type counters struct {
+	matches     atomic.Int64
+	evaluations atomic.Int64
+	mutations   atomic.Int64
+	errors      atomic.Int64
+
+	mu      sync.Mutex
+	err     string
+	errTime time.Time
+}
+
+// NewRegistry returns a Registry of the mutators of system.
+func NewRegistry(system *mutation.System) *Registry {
+	return &Registry{system: system, entries: map[types.ID]*entry{}}
+}
+
+// Observe records that obj was reconciled into m, which is nil if building
+// the mutator failed, and the error building or ingesting it, if any.
+func (r *Registry) Observe(obj client.Object, m types.Mutator, err error) {
+	if r == nil {
+		return
+	}
+	id := types.MakeID(obj)
+	var match interface{}
+	if content, convErr := runtime.DefaultUnstructuredConverter.ToUnstructured(obj); convErr == nil {
+		match, _, _ = unstructured.NestedFieldNoCopy(content, "spec", "match")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[id]
+	if !ok {
+		e = &entry{counters: &counters{}}
+		r.entries[id] = e
+	}
+	e.generation = obj.GetGeneration()
+	e.match = match
+	if m != nil {
+		e.mutator = m
+	}
+	e.err, e.errTime = "", time.Time{}
+	if err != nil {
+		e.err, e.errTime = err.Error(), time.Now()
+	}
+}
+
+// Forget removes the mutator of the deleted object id.
+func (r *Registry) Forget(id types.ID) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, id)
+}
+
+// Mutator returns m counting its calls. The counters are shared by every
+// generation of the mutator of an object.
+func (r *Registry) Mutator(m types.Mutator) types.Mutator {
+	if r == nil || m == nil {
+		return m
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[m.ID()]
+	if !ok {
+		e = &entry{counters: &counters{}}
+		r.entries[m.ID()] = e
+	}
+	return &mutator{Mutator: m, counters: e.counters}
+}
+
+// MutatorInfo is the state of a mutator.
+type MutatorInfo struct {
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	State      string `json:"state"`
+	// Loaded is true if a generation of the mutator is in the mutation
+	// system.
+	Loaded bool `json:"loaded"`
+	// Compiled is false if no generation of the mutator could be built,
+	// e.g. because its Rego did not compile.
+	Compiled bool `json:"compiled"`
+	// Conflicts are the mutators the mutator conflicts with.
+	Conflicts []string    `json:"conflicts,omitempty"`
+	Match     interface{} `json:"match,omitempty"`
+	// LastIngestionError is the error of the last reconciliation.
+	LastIngestionError *ErrorInfo `json:"lastIngestionError,omitempty"`
+	// LastEvaluationError is the last error returned to the mutation
+	// system. Errors of rules ignoring failures are not returned.
+	LastEvaluationError *ErrorInfo `json:"lastEvaluationError,omitempty"`
+	Counters            Counters   `json:"counters"`
+}
+
+// ErrorInfo is an error and when it happened.
+type ErrorInfo struct {
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Counters count the calls of a mutator since this replica started.
+type Counters struct {
+	// Matches counts the objects the mutator matched.
+	Matches int64 `json:"matches"`
+	// Evaluations counts the calls of Mutate.
+	Evaluations int64 `json:"evaluations"`
+	// Mutations counts the evaluations that changed the object.
+	Mutations int64 `json:"mutations"`
+	// Errors counts the evaluations that failed.
+	Errors int64 `json:"errors"`
+}
+
+// Mutators returns the state of every mutator in the order the mutation
+// system evaluates them.
+func (r *Registry) Mutators() []MutatorInfo {
+	r.mu.RLock()
+	ids := make([]types.ID, 0, len(r.entries))
+	for id := range r.entries {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	sort.Slice(ids, func(i, j int) bool {
+		return evaluatedBefore(ids[i], ids[j])
+	})
+
+	infos := make([]MutatorInfo, 0, len(ids))
+	for _, id := range ids {
+		if info, ok := r.mutatorInfo(id); ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+func (r *Registry) mutatorInfo(id types.ID) (MutatorInfo, bool) {
+	// The mutation system is asked first so that its lock is never taken
+	// while holding r.mu.
+	loaded := r.system.Get(id) != nil
+	var conflicts []string
+	for conflict := range r.system.GetConflicts(id) {
+		if conflict != id {
+			conflicts = append(conflicts, conflict.String())
+		}
+	}
+	sort.Strings(conflicts)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[id]
+	if !ok {
+		return MutatorInfo{}, false
+	}
+	info := MutatorInfo{
+		Kind:       id.Kind,
+		Namespace:  id.Namespace,
+		Name:       id.Name,
+		Generation: e.generation,
+		Loaded:     loaded,
+		Compiled:   e.mutator != nil,
+		Conflicts:  conflicts,
+		Match:      e.match,
+		Counters: Counters{
+			Matches:     e.counters.matches.Load(),
+			Evaluations: e.counters.evaluations.Load(),
+			Mutations:   e.counters.mutations.Load(),
+			Errors:      e.counters.errors.Load(),
+		},
+	}
+	if e.err != "" {
+		info.LastIngestionError = &ErrorInfo{Message: e.err, Time: e.errTime}
+	}
+	e.counters.mu.Lock()
+	if e.counters.err != "" {
+		info.LastEvaluationError = &ErrorInfo{Message: e.counters.err, Time: e.counters.errTime}
+	}
+	e.counters.mu.Unlock()
+
+	switch {
+	case e.err != "":
+		info.State = StateFailed
+	case !loaded:
+		info.State = StateSuspended
+	case len(conflicts) > 0:
+		info.State = StateConflict
+	default:
+		info.State = StateActive
+	}
+	return info, true
+}
+
+// Modules returns the Rego modules the last built mutator of id was
+// prepared from, by file name, or false if it does not evaluate Rego.
+func (r *Registry) Modules(id types.ID) (map[string]*ast.Module, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[id]
+	if !ok {
+		return nil, false
+	}
+	source, ok := e.mutator.(moduleSource)
+	if !ok {
+		return nil, false
+	}
+	return source.Modules(), true
+}
+
+// evaluatedBefore orders mutators like the mutation system, which
+// evaluates them sorted by group, kind, namespace and name.
+func evaluatedBefore(a, b types.ID) bool {
+	if a.Group != b.Group {
+		return a.Group < b.Group
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,169 @@
+// Package decisionlog records every mutation applied by the webhook as a
+// structured decision, similar to the decision logs of OPA. Decisions are
+// buffered in memory and written to a Sink in batches, so admission
+// requests never wait for the sink.
+package decisionlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"gomodules.xyz/jsonpatch/v2"
+	"kubesphere.io/muato/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("decisionlog").WithValues(logging.Process, "decisionlog")
+
+const (
+	// maxBackoff bounds the delay between attempts to write a batch.
+	maxBackoff = time.Minute
+	// shutdownTimeout bounds writing the last batch on shutdown.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Decision is the record of one mutation.
+type Decision struct {
+	Timestamp time.Time `json:"timestamp"`
+	// RequestUID is the UID of the admission request.
+	RequestUID string          `json:"request_uid"`
+	User       string          `json:"user"`
+	Operation  string          `json:"operation"`
+	Object     ObjectReference `json:"object"`
+	Mutator    MutatorID       `json:"mutator"`
+	// Patch is the JSON patch the mutator applied.
+	Patch []jsonpatch.Operation `json:"patch"`
+	// LatencyNanoseconds is how long the mutator took.
+	LatencyNanoseconds int64 `json:"latency_ns"`
+}
+
+// ObjectReference identifies the mutated object.
+type ObjectReference struct {
+	APIVersion   string `json:"apiVersion"`
+	Kind         string `json:"kind"`
+	Namespace    string `json:"namespace,omitempty"`
+	Name         string `json:"name,omitempty"`
+	GenerateName string `json:"generateName,omitempty"`
+}
+
+// MutatorID identifies the mutator and the generation it was built from.
+type MutatorID struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+}
+
+// Sink writes batches of decisions.
+type Sink interface {
+	// Write writes decisions in order and returns how many were written,
+	// so that only the others are written again after an error.
+	Write(ctx context.Context, decisions []Decision) (int, error)
+}
+
+// Logger buffers decisions and writes them to its Sink.
+type Logger struct {
+	sink          Sink
+	decisions     chan Decision
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// Decisions are logged by every replica.
+var _ manager.LeaderElectionRunnable = &Logger{}
+
+// NewLogger returns a Logger buffering up to bufferSize decisions and
+// writing them to sink in batches of batchSize, or every flushInterval.
+func NewLogger(sink Sink, bufferSize, batchSize int, flushInterval time.Duration) *Logger {
+	return &Logger{
+		sink:          sink,
+		decisions:     make(chan Decision, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// Log queues decision. It never blocks: if the buffer is full because the
+// sink can not keep up, the decision is dropped and counted. It accepts a
+// nil receiver.
+func (l *Logger) Log(decision Decision) {
+	if l == nil {
+		return
+	}
+	select {
+	case l.decisions <- decision:
+	default:
+		metrics.DroppedDecisions.Inc()
+	}
+}
+
+// Enabled returns true if decisions are logged.
+func (l *Logger) Enabled() bool {
+	return l != nil
+}
+
+// Start writes queued decisions until ctx is done. The decisions of a batch
+// that were not written are retried with backoff; meanwhile new decisions
+// fill the buffer.
+func (l *Logger) Start(ctx context.Context) error {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Decision
+	var backoff time.Duration
+	var retryAt time.Time
+	for {
+		in := l.decisions
+		if len(batch) >= l.batchSize {
+			in = nil
+		}
+		select {
+		case <-ctx.Done():
+			l.shutdown(batch)
+			return nil
+		case decision := <-in:
+			batch = append(batch, decision)
+			if len(batch) < l.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		if len(batch) == 0 || time.Now().Before(retryAt) {
+			continue
+		}
+		written, err := l.sink.Write(ctx, batch)
+		if err != nil {
+			batch = batch[written:]
+			backoff = min(max(2*backoff, l.flushInterval), maxBackoff)
+			retryAt = time.Now().Add(backoff)
+			log.Error(err, "Failed to write decisions", "decisions", len(batch), "retryIn", backoff)
+			continue
+		}
+		batch, backoff, retryAt = nil, 0, time.Time{}
+	}
+}
+
+// shutdown writes batch and the buffered decisions once.
+func (l *Logger) shutdown(batch []Decision) {
+	for drained := false; !drained; {
+		select {
+		case decision := <-l.decisions:
+			batch = append(batch, decision)
+		default:
+			drained = true
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if written, err := l.sink.Write(ctx, batch); err != nil {
+		log.Error(err, "Failed to write decisions on shutdown", "decisions", len(batch)-written)
+	}
+}
+
+func (l *Logger) NeedLeaderElection() bool {
+	return false
+}
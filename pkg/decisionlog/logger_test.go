@@ -0,0 +1,139 @@
+package decisionlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeSink records the request UIDs of the decisions written.
+type fakeSink struct {
+	mu sync.Mutex
+	// failures are the number of decisions written by the first writes,
+	// which then fail.
+	failures []int
+	written  []string
+}
+
+func (s *fakeSink) Write(_ context.Context, decisions []Decision) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(decisions)
+	var err error
+	if len(s.failures) > 0 {
+		n, err = min(s.failures[0], n), errors.New("sink unavailable")
+		s.failures = s.failures[1:]
+	}
+	for _, decision := range decisions[:n] {
+		s.written = append(s.written, decision.RequestUID)
+	}
+	return n, err
+}
+
+func (s *fakeSink) Written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.written...)
+}
+
+func TestLoggerStart(t *testing.T) {
+	tests := []struct {
+		name          string
+		decisions     int
+		batchSize     int
+		flushInterval time.Duration
+		failures      []int
+		// shutdown stops the logger right away, leaving the decisions to
+		// the final write.
+		shutdown bool
+	}{{
+		name:          "full batches",
+		decisions:     6,
+		batchSize:     2,
+		flushInterval: time.Hour,
+	}, {
+		name:          "flush interval",
+		decisions:     3,
+		batchSize:     100,
+		flushInterval: 10 * time.Millisecond,
+	}, {
+		name:          "failed write is retried",
+		decisions:     4,
+		batchSize:     2,
+		flushInterval: 10 * time.Millisecond,
+		failures:      []int{0},
+	}, {
+		name:          "partial write retries the rest",
+		decisions:     4,
+		batchSize:     4,
+		flushInterval: 10 * time.Millisecond,
+		failures:      []int{1, 2},
+	}, {
+		name:          "shutdown writes buffered decisions",
+		decisions:     3,
+		batchSize:     100,
+		flushInterval: time.Hour,
+		shutdown:      true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fakeSink{failures: tt.failures}
+			l := NewLogger(sink, 10, tt.batchSize, tt.flushInterval)
+			var want []string
+			for i := 0; i < tt.decisions; i++ {
+				uid := fmt.Sprint(i)
+				l.Log(Decision{RequestUID: uid})
+				want = append(want, uid)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- l.Start(ctx)
+			}()
+			if !tt.shutdown {
+				deadline := time.Now().Add(5 * time.Second)
+				for len(sink.Written()) < tt.decisions && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Start() = %v", err)
+			}
+
+			if diff := cmp.Diff(want, sink.Written()); diff != "" {
+				t.Errorf("written decisions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoggerLogDropsWhenFull(t *testing.T) {
+	sink := &fakeSink{}
+	l := NewLogger(sink, 1, 10, time.Hour)
+	l.Log(Decision{RequestUID: "0"})
+	l.Log(Decision{RequestUID: "1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"0"}, sink.Written()); diff != "" {
+		t.Errorf("written decisions (-want +got):\n%s", diff)
+	}
+}
+
+func TestNilLoggerLog(t *testing.T) {
+	var l *Logger
+	l.Log(Decision{})
+	if l.Enabled() {
+		t.Error("Enabled() = true, want false")
+	}
+}
@@ -0,0 +1,148 @@
+package decisionlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// writerSink writes decisions as JSON lines.
+type writerSink struct {
+	w io.Writer
+}
+
+// NewWriterSink returns a Sink writing JSON lines to w, e.g. os.Stdout.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Write(_ context.Context, decisions []Decision) (int, error) {
+	encoder := json.NewEncoder(s.w)
+	for i := range decisions {
+		if err := encoder.Encode(&decisions[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(decisions), nil
+}
+
+// fileSink writes JSON lines to a file and rotates it by size.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// NewFileSink returns a Sink writing JSON lines to the file at path. Once
+// the file would exceed maxSize bytes it is renamed to path.1, path.1 to
+// path.2 and so on; files beyond maxBackups are removed.
+func NewFileSink(path string, maxSize int64, maxBackups int) Sink {
+	return &fileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+}
+
+func (s *fileSink) Write(_ context.Context, decisions []Decision) (int, error) {
+	for i := range decisions {
+		line, err := json.Marshal(&decisions[i])
+		if err != nil {
+			return i, err
+		}
+		line = append(line, '\n')
+		if s.file == nil {
+			if err := s.open(); err != nil {
+				return i, err
+			}
+		}
+		if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err := s.rotate(); err != nil {
+				return i, err
+			}
+		}
+		n, err := s.file.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(decisions), nil
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", s.path, i) }
+	if err := os.Remove(backup(s.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if s.maxBackups > 0 {
+		if err := os.Rename(s.path, backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// httpSink posts batches of decisions as JSON arrays.
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a Sink posting each batch as a JSON array to url.
+func NewHTTPSink(url string, timeout time.Duration) Sink {
+	return &httpSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Write posts the batch in one request, so either all decisions are
+// written or none.
+func (s *httpSink) Write(ctx context.Context, decisions []Decision) (int, error) {
+	body, err := json.Marshal(decisions)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("posting decisions to %s: %s", s.url, resp.Status)
+	}
+	return len(decisions), nil
+}
@@ -0,0 +1,121 @@
+// Package metrics defines the Prometheus metrics of Mutato. They are
+// served by the controller-runtime metrics server.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "mutato"
+
+var (
+	// DryRunMutations counts the changes that were not applied because of
+	// the enforcement action of the mutator.
+	DryRunMutations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dryrun_mutations_total",
+		Help:      "Number of changes not applied because of the enforcement action of the mutator.",
+	}, []string{"kind", "name", "enforcement_action"})
+
+	// IgnoredFailures counts the failures of mutators that were skipped
+	// because of their failure policy.
+	IgnoredFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ignored_failures_total",
+		Help:      "Number of mutator failures ignored because of the failure policy of the mutator.",
+	}, []string{"kind", "name"})
+
+	// CircuitBreakerTrips counts how often the circuit breaker of a Dynamic
+	// suspended its rule.
+	CircuitBreakerTrips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_trips_total",
+		Help:      "Number of times the circuit breaker of a Dynamic suspended its rule.",
+	}, []string{"name"})
+
+	// DroppedDecisions counts the decisions dropped because the decision log
+	// sink could not keep up.
+	DroppedDecisions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "decision_logs_dropped_total",
+		Help:      "Number of decisions dropped because the decision log buffer was full.",
+	})
+
+	// AdmissionRequests counts the admission requests of the mutating
+	// webhook by the kind of the object, the operation and the result:
+	// skipped, allowed, mutated, denied or error.
+	AdmissionRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admission_requests_total",
+		Help:      "Number of admission requests of the mutating webhook.",
+	}, []string{"kind", "operation", "result"})
+
+	// AdmissionDuration observes how long the mutating webhook takes to
+	// answer admission requests.
+	AdmissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "admission_duration_seconds",
+		Help:      "Duration of admission requests of the mutating webhook.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"kind", "operation", "result"})
+
+	// MutatorIngestions counts the attempts to add mutators to the mutation
+	// system by the kind of the mutator and the outcome, active or error.
+	MutatorIngestions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mutator_ingestions_total",
+		Help:      "Number of attempts to add mutators to the mutation system.",
+	}, []string{"kind", "status"})
+
+	// DynamicMatches counts the objects matched by a Dynamic. The mutation
+	// system matches an object once per iteration until it converges.
+	DynamicMatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamic_matches_total",
+		Help:      "Number of objects matched by a Dynamic, once per iteration of the mutation system.",
+	}, []string{"name"})
+
+	// DynamicEvaluations counts the evaluations of the rule of a Dynamic.
+	DynamicEvaluations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamic_evaluations_total",
+		Help:      "Number of evaluations of the rule of a Dynamic.",
+	}, []string{"name"})
+
+	// DynamicErrors counts the evaluations of a Dynamic that failed,
+	// including those ignored because of its failure policy.
+	DynamicErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamic_errors_total",
+		Help:      "Number of failed evaluations of a Dynamic.",
+	}, []string{"name"})
+
+	// DynamicDuration observes how long the evaluation of a Dynamic takes,
+	// from evaluating the rule to checking its output.
+	DynamicDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dynamic_duration_seconds",
+		Help:      "Duration of the evaluations of a Dynamic.",
+		Buckets:   regoBuckets,
+	}, []string{"name"})
+
+	// RegoDuration observes the timers OPA reports for the evaluations of
+	// the rule of a Dynamic, such as rego_query_eval.
+	RegoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rego_duration_seconds",
+		Help:      "Duration of the stages of Rego evaluations of a Dynamic, as timed by OPA.",
+		Buckets:   regoBuckets,
+	}, []string{"name", "timer"})
+)
+
+// regoBuckets are the histogram buckets of rule evaluations, which mostly
+// take well below a millisecond.
+var regoBuckets = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(DryRunMutations, IgnoredFailures, CircuitBreakerTrips, DroppedDecisions,
+		AdmissionRequests, AdmissionDuration, MutatorIngestions,
+		DynamicMatches, DynamicEvaluations, DynamicErrors, DynamicDuration, RegoDuration)
+}
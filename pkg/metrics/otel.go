@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/metrics/exporters/view"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// openTelemetryNamespace prefixes the metrics of the OpenTelemetry
+// instruments, which are all created by Gatekeeper.
+const openTelemetryNamespace = "gatekeeper"
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// ExportOpenTelemetry makes the global OpenTelemetry meter provider collect
+// the instruments of Gatekeeper, such as the mutator ingestion and mutation
+// system convergence metrics, and serves them with the Prometheus metrics.
+// It must be called once, after the views of Gatekeeper are registered by
+// its package initializers.
+func ExportOpenTelemetry() {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(view.Views()...),
+	))
+	ctrlmetrics.Registry.MustRegister(&openTelemetryCollector{reader: reader})
+}
+
+// openTelemetryCollector converts the metrics of an OpenTelemetry reader
+// on every scrape. It is unchecked: the metrics are only known once
+// collected.
+type openTelemetryCollector struct {
+	reader *sdkmetric.ManualReader
+}
+
+func (c *openTelemetryCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *openTelemetryCollector) Collect(ch chan<- prometheus.Metric) {
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := c.reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		logf.Log.WithName("metrics").Error(err, "failed to collect OpenTelemetry metrics")
+		return
+	}
+	for _, scope := range resourceMetrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			name := prometheus.BuildFQName(openTelemetryNamespace, "", invalidNameChars.ReplaceAllString(m.Name, "_"))
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				collectSum(ch, name, m.Description, data)
+			case metricdata.Sum[float64]:
+				collectSum(ch, name, m.Description, data)
+			case metricdata.Gauge[int64]:
+				collectGauge(ch, name, m.Description, data)
+			case metricdata.Gauge[float64]:
+				collectGauge(ch, name, m.Description, data)
+			case metricdata.Histogram[int64]:
+				collectHistogram(ch, name, m.Description, data)
+			case metricdata.Histogram[float64]:
+				collectHistogram(ch, name, m.Description, data)
+			}
+		}
+	}
+}
+
+func collectSum[N int64 | float64](ch chan<- prometheus.Metric, name, help string, data metricdata.Sum[N]) {
+	valueType := prometheus.GaugeValue
+	if data.IsMonotonic {
+		valueType = prometheus.CounterValue
+	}
+	for _, point := range data.DataPoints {
+		desc, values := describe(name, help, point.Attributes)
+		ch <- prometheus.MustNewConstMetric(desc, valueType, float64(point.Value), values...)
+	}
+}
+
+func collectGauge[N int64 | float64](ch chan<- prometheus.Metric, name, help string, data metricdata.Gauge[N]) {
+	for _, point := range data.DataPoints {
+		desc, values := describe(name, help, point.Attributes)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(point.Value), values...)
+	}
+}
+
+func collectHistogram[N int64 | float64](ch chan<- prometheus.Metric, name, help string, data metricdata.Histogram[N]) {
+	for _, point := range data.DataPoints {
+		// Prometheus buckets are cumulative, OpenTelemetry buckets are not.
+		// The last OpenTelemetry bucket is the +Inf bucket of Prometheus.
+		buckets := make(map[float64]uint64, len(point.Bounds))
+		var count uint64
+		for i, bound := range point.Bounds {
+			count += point.BucketCounts[i]
+			buckets[bound] = count
+		}
+		desc, values := describe(name, help, point.Attributes)
+		ch <- prometheus.MustNewConstHistogram(desc, point.Count, float64(point.Sum), buckets, values...)
+	}
+}
+
+// describe returns the description of a metric with the attributes as
+// labels, and the label values.
+func describe(name, help string, attributes attribute.Set) (*prometheus.Desc, []string) {
+	labels := make([]string, 0, attributes.Len())
+	values := make([]string, 0, attributes.Len())
+	for iter := attributes.Iter(); iter.Next(); {
+		attr := iter.Attribute()
+		labels = append(labels, invalidNameChars.ReplaceAllString(string(attr.Key), "_"))
+		values = append(values, attr.Value.Emit())
+	}
+	return prometheus.NewDesc(name, help, labels, nil), values
+}
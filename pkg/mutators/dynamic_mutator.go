@@ -1,6 +1,7 @@
 package mutators
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,10 +13,23 @@ import (
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
 	mutationtypes "github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/opa/ast"
+	opametrics "github.com/open-policy-agent/opa/metrics"
 	"github.com/open-policy-agent/opa/rego"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
 	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/breaker"
+	"kubesphere.io/muato/pkg/bundles"
+	"kubesphere.io/muato/pkg/metrics"
+	"kubesphere.io/muato/pkg/redact"
+	"kubesphere.io/muato/pkg/report"
+	"kubesphere.io/muato/pkg/schemas"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+	"time"
 )
 
 var log = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "dynamic")
@@ -24,6 +38,13 @@ var log = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation",
 type Mutator struct {
 	id      types.ID
 	dynamic *mutationsv1alpha1.Dynamic
+	// revision identifies the loaded bundle or template, if any.
+	revision string
+	query    *rego.PreparedEvalQuery
+	// schemas validates the mutated objects, if not nil.
+	schemas *schemas.Validator
+	// breaker records the failures of the rule, if not nil.
+	breaker *breaker.Breaker
 }
 
 // Mutator implements mutatorWithSchema.
@@ -39,6 +60,9 @@ func (m *Mutator) Matches(mutable *types.Mutable) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if matches {
+		metrics.DynamicMatches.WithLabelValues(m.id.Name).Inc()
+	}
 	return matches, nil
 }
 
@@ -47,31 +71,225 @@ func (m *Mutator) TerminalType() parser.NodeType {
 }
 
 func (m *Mutator) Mutate(mutable *types.Mutable) (bool, error) {
-	evalQuery, err := rego.New(rego.Query(defaultRegoQuery), rego.Module(defaultRegoFileName, m.dynamic.Spec.Rego)).PrepareForEval(context.Background())
+	start := time.Now()
+	mutated, err := m.mutate(mutable)
+	metrics.DynamicEvaluations.WithLabelValues(m.id.Name).Inc()
+	metrics.DynamicDuration.WithLabelValues(m.id.Name).Observe(time.Since(start).Seconds())
 	if err != nil {
-		log.Error(err, "Failed to prepare rego query", "mutator", m.id)
-		return false, err
+		metrics.DynamicErrors.WithLabelValues(m.id.Name).Inc()
+	}
+	m.breaker.Record(err)
+	if err != nil && m.dynamic.Spec.FailurePolicy == mutationsv1alpha1.FailurePolicyIgnore {
+		if r := report.For(mutable); r != nil {
+			r.AddFailure(report.Failure{ID: m.id, Err: err})
+		} else {
+			log.Info("Ignoring failure", "mutator", m.id, "error", err.Error())
+		}
+		return false, nil
 	}
+	return mutated, err
+}
 
+func (m *Mutator) mutate(mutable *types.Mutable) (bool, error) {
+	start := time.Now()
 	// The policy decision is contained in the results returned by the Eval() call. You can inspect the decision and handle it accordingly.
-	results, err := evalQuery.Eval(context.Background(), rego.EvalInput(mutable.Object.Object))
+	// Unless the Dynamic opts in, rules only see masked Secret data.
+	evalInput := mutable.Object.Object
+	masked := !m.dynamic.Spec.ExposeSecretData && redact.IsSecret(evalInput)
+	if masked {
+		evalInput = redact.MaskSecretData(evalInput)
+	}
+	regoMetrics := opametrics.New()
+	results, err := m.query.Eval(context.Background(), rego.EvalInput(evalInput), rego.EvalMetrics(regoMetrics))
+	m.observeRegoTimers(regoMetrics)
 	if err != nil {
 		log.Error(err, "Failed to evaluate rego query", "mutator", m.id)
 		return false, err
 	}
 
 	if len(results) > 0 && len(results[0].Expressions) > 0 {
-		if content, ok := results[0].Expressions[0].Value.(map[string]interface{}); ok {
+		document, _ := results[0].Expressions[0].Value.(map[string]interface{})
+		warnings, err := ruleStrings(document, warningsRule)
+		if err != nil {
+			return false, fmt.Errorf("dynamic %q: %w", m.id.Name, err)
+		}
+		denials, err := ruleStrings(document, denyRule)
+		if err != nil {
+			return false, fmt.Errorf("dynamic %q: %w", m.id.Name, err)
+		}
+		action := m.dynamic.Spec.EnforcementAction
+		if len(denials) > 0 {
+			if m.dynamic.Spec.DenyPolicy == mutationsv1alpha1.DenyPolicyDeny {
+				return false, m.deny(mutable, action, denials)
+			}
+			log.V(1).Info("Ignoring denial", "mutator", m.id, "messages", denials)
+		}
+		content, ok := document[modifiedRule].(map[string]interface{})
+		if ok && masked {
+			redact.RestoreSecretData(content, mutable.Object.Object)
+		}
+		if action != "" && action != mutationsv1alpha1.EnforcementActionApply {
+			m.warn(mutable, warnings)
+			if !ok {
+				return false, nil
+			}
+			if err := m.checkOutput(mutable.Object, content); err != nil {
+				log.Info("Dry run output is invalid", "mutator", m.id, "error", err.Error())
+				return false, nil
+			}
 			input, _ := json.Marshal(mutable.Object)
 			output, _ := json.Marshal(content)
-			mutable.Object.SetUnstructuredContent(content)
-			log.Info("Mutating object", "mutator", m.id, "input", string(input), "output", string(output))
-			return true, nil
+			return false, m.dryRun(mutable, action, input, output)
+		}
+		if !ok {
+			m.warn(mutable, warnings)
+			return false, nil
+		}
+		if err := m.checkOutput(mutable.Object, content); err != nil {
+			return false, err
 		}
+		input, _ := json.Marshal(mutable.Object)
+		output, _ := json.Marshal(content)
+		// A rule defining modified as its input changes nothing; reporting
+		// it as a mutation would record it in the decision log and the
+		// provenance annotations.
+		if bytes.Equal(input, output) {
+			m.warn(mutable, warnings)
+			return false, nil
+		}
+		if r := report.For(mutable); r != nil {
+			r.AddMutation(report.Mutation{
+				ID:         m.id,
+				Generation: m.dynamic.GetGeneration(),
+				Input:      input,
+				Output:     output,
+				Latency:    time.Since(start),
+			})
+		}
+		mutable.Object.SetUnstructuredContent(content)
+		log.V(1).Info("Mutating object", "mutator", m.id)
+		m.warn(mutable, warnings)
+		return true, nil
 	}
 	return false, nil
 }
 
+// warn reports the warnings of the rule for the admission response.
+func (m *Mutator) warn(mutable *types.Mutable, warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	if r := report.For(mutable); r != nil {
+		r.AddWarnings(warnings...)
+	} else {
+		log.Info("Rule warnings", "mutator", m.id, "warnings", warnings)
+	}
+}
+
+// deny reports that the rule denies the object. The object is rejected
+// only if the rule is enforced.
+func (m *Mutator) deny(mutable *types.Mutable, action mutationsv1alpha1.EnforcementAction, messages []string) error {
+	switch action {
+	case "", mutationsv1alpha1.EnforcementActionApply:
+		if r := report.For(mutable); r != nil {
+			r.AddDenial(report.Denial{ID: m.id, Messages: messages})
+			return nil
+		}
+		// Without a report the object can not be rejected.
+		return fmt.Errorf("dynamic %q denied the object: %s", m.id.Name, strings.Join(messages, "; "))
+	case mutationsv1alpha1.EnforcementActionWarn:
+		warnings := make([]string, 0, len(messages))
+		for _, message := range messages {
+			warnings = append(warnings, fmt.Sprintf("Dynamic %s would deny the object: %s", m.id.Name, message))
+		}
+		m.warn(mutable, warnings)
+	}
+	log.Info("Dry run denial", "mutator", m.id, "enforcementAction", action, "messages", messages)
+	return nil
+}
+
+// ruleStrings returns the strings of the named rule of document, which
+// must be a set of strings if defined.
+func ruleStrings(document map[string]interface{}, rule string) ([]string, error) {
+	value, ok := document[rule]
+	if !ok {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("data.mutating.%s must be a set of strings", rule)
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("data.mutating.%s must be a set of strings", rule)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// identityFields are the fields identifying an object, which a Dynamic must
+// not change.
+var identityFields = [][]string{
+	{"apiVersion"},
+	{"kind"},
+	{"metadata", "name"},
+	{"metadata", "namespace"},
+	{"metadata", "uid"},
+}
+
+// checkOutput returns an error if the output of the Rego query changes the
+// identity of obj, does not match the schema of its kind, or sets invalid
+// container resources.
+func (m *Mutator) checkOutput(obj *unstructured.Unstructured, output map[string]interface{}) error {
+	for _, fields := range identityFields {
+		before, _, _ := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+		after, _, _ := unstructured.NestedFieldNoCopy(output, fields...)
+		if !reflect.DeepEqual(before, after) {
+			return fmt.Errorf("dynamic %q must not change %s", m.id.Name, strings.Join(fields, "."))
+		}
+	}
+	mutated := &unstructured.Unstructured{Object: output}
+	errs := append(m.schemas.Validate(mutated), validateResources(mutated)...)
+	if len(errs) > 0 {
+		return fmt.Errorf("dynamic %q produced an invalid %s: %w", m.id.Name, obj.GetKind(), errs.ToAggregate())
+	}
+	return nil
+}
+
+// observeRegoTimers records the timers OPA reported for an evaluation.
+func (m *Mutator) observeRegoTimers(regoMetrics opametrics.Metrics) {
+	timers, ok := regoMetrics.(opametrics.TimerMetrics)
+	if !ok {
+		return
+	}
+	for key, value := range timers.Timers() {
+		ns, ok := value.(int64)
+		if !ok {
+			continue
+		}
+		// OPA formats the keys as timer_<name>_ns.
+		timer := strings.TrimSuffix(strings.TrimPrefix(key, "timer_"), "_ns")
+		metrics.RegoDuration.WithLabelValues(m.id.Name, timer).Observe(time.Duration(ns).Seconds())
+	}
+}
+
+// dryRun reports the change from input to output instead of applying it.
+func (m *Mutator) dryRun(mutable *types.Mutable, action mutationsv1alpha1.EnforcementAction, input, output []byte) error {
+	if bytes.Equal(input, output) {
+		return nil
+	}
+	if r := report.For(mutable); r != nil {
+		r.AddDryRun(report.DryRun{ID: m.id, Action: action, Input: input, Output: output})
+	} else {
+		// The values of the change may be sensitive and are not logged.
+		log.Info("Dry run", "mutator", m.id, "enforcementAction", action)
+	}
+	return nil
+}
+
 func (m *Mutator) MustTerminate() bool {
 	return true
 }
@@ -92,6 +310,9 @@ func (m *Mutator) HasDiff(mutator types.Mutator) bool {
 	if !cmp.Equal(toCheck.dynamic.Spec, m.dynamic.Spec) {
 		return true
 	}
+	if toCheck.revision != m.revision {
+		return true
+	}
 
 	return false
 }
@@ -101,32 +322,95 @@ func (m *Mutator) Path() parser.Path {
 }
 
 func (m *Mutator) DeepCopy() types.Mutator {
+	// prepared queries are safe for concurrent use, so they are shared
+	// between copies.
 	res := &Mutator{
-		id:      m.id,
-		dynamic: m.dynamic.DeepCopy(),
+		id:       m.id,
+		dynamic:  m.dynamic.DeepCopy(),
+		revision: m.revision,
+		query:    m.query,
+		schemas:  m.schemas,
+		breaker:  m.breaker,
 	}
 	return res
 }
 
+// Modules returns the Rego modules the query was prepared from, by file
+// name, after templates are rendered and bundles are loaded.
+func (m *Mutator) Modules() map[string]*ast.Module {
+	return m.query.Modules()
+}
+
 func (m *Mutator) String() string {
 	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.dynamic.GetGeneration())
 }
 
 const (
-	defaultRegoQuery    = "data.mutating.modified"
+	// defaultRegoQuery collects the rules Mutato reads into one document,
+	// so that one evaluation yields all of them without evaluating the
+	// other rules of the package.
+	defaultRegoQuery    = `{rule: value | rule := ["modified", "warnings", "deny"][_]; value := data.mutating[rule]}`
+	modifiedRule        = "modified"
+	warningsRule        = "warnings"
+	denyRule            = "deny"
 	defaultRegoFileName = "mutating.rego"
 )
 
 // MutatorForDynamic returns a mutator built from the given dynamic instance.
-func MutatorForDynamic(dynamic *mutationsv1alpha1.Dynamic) (*Mutator, error) {
+// loader may be nil, in which case bundle sources are rejected. templates
+// reads the DynamicTemplate referenced by dynamic. validator may be nil, in
+// which case mutated objects are not validated against their schema. b
+// records the failures of the rule and may be nil.
+func MutatorForDynamic(dynamic *mutationsv1alpha1.Dynamic, loader *bundles.Loader, templates client.Reader, validator *schemas.Validator, b *breaker.Breaker) (*Mutator, error) {
 	log.V(1).Info("Creating mutator", "dynamic", dynamic)
 	if err := core.ValidateName(dynamic.Name); err != nil {
 		return nil, err
 	}
+
+	if dynamic.Spec.Parameters != nil && dynamic.Spec.TemplateRef == "" {
+		return nil, fmt.Errorf("parameters require templateRef")
+	}
+
+	options := []func(*rego.Rego){rego.Query(defaultRegoQuery)}
+	var revision string
+	switch {
+	case dynamic.Spec.Bundle != nil:
+		if dynamic.Spec.Rego != "" || dynamic.Spec.TemplateRef != "" {
+			return nil, fmt.Errorf("only one of rego, bundle and templateRef may be set")
+		}
+		if loader == nil {
+			return nil, fmt.Errorf("bundle sources are not supported")
+		}
+		b, rev, err := loader.Load(context.Background(), dynamic)
+		if err != nil {
+			return nil, err
+		}
+		options, revision = append(options, rego.ParsedBundle(dynamic.Name, b)), rev
+	case dynamic.Spec.TemplateRef != "":
+		if dynamic.Spec.Rego != "" {
+			return nil, fmt.Errorf("only one of rego, bundle and templateRef may be set")
+		}
+		templateOpts, rev, err := templateOptions(dynamic, templates)
+		if err != nil {
+			return nil, err
+		}
+		options, revision = append(options, templateOpts...), rev
+	default:
+		options = append(options, rego.Module(defaultRegoFileName, dynamic.Spec.Rego))
+	}
+	query, err := rego.New(options...).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("preparing rego query: %w", err)
+	}
+
 	// This is not always set by the kubernetes API server
 	dynamic.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "Dynamic"})
 	return &Mutator{
-		id:      types.MakeID(dynamic),
-		dynamic: dynamic.DeepCopy(),
+		id:       types.MakeID(dynamic),
+		dynamic:  dynamic.DeepCopy(),
+		revision: revision,
+		query:    &query,
+		schemas:  validator,
+		breaker:  b,
 	}, nil
 }
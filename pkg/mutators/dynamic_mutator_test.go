@@ -0,0 +1,106 @@
+package mutators
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// resourceRule sets the limit of the named resource of the first container.
+const resourceRule = `package mutating
+
+modified := json.patch(input, [{"op": "add", "path": "/spec/containers/0/resources", "value": {"limits": {%q: %q}}}])
+`
+
+func TestDynamicMutateResources(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		value    string
+		wantErr  bool
+	}{{
+		name:     "memory",
+		resource: "memory",
+		value:    "512Gi",
+	}, {
+		name:     "milli cpu",
+		resource: "cpu",
+		value:    "500m",
+	}, {
+		name:     "milli memory",
+		resource: "memory",
+		value:    "512000000000m",
+		wantErr:  true,
+	}, {
+		name:     "fractional memory",
+		resource: "memory",
+		value:    "1.5",
+		wantErr:  true,
+	}, {
+		name:     "hugepages",
+		resource: "hugepages-2Mi",
+		value:    "0.5k",
+	}, {
+		name:     "unparsable quantity",
+		resource: "cpu",
+		value:    "one",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamic := &mutationsv1alpha1.Dynamic{
+				ObjectMeta: metav1.ObjectMeta{Name: "resources"},
+				Spec:       mutationsv1alpha1.DynamicSpec{Rego: fmt.Sprintf(resourceRule, tt.resource, tt.value)},
+			}
+			m, err := MutatorForDynamic(dynamic, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("MutatorForDynamic() = %v", err)
+			}
+			mutable := &types.Mutable{Object: testPod()}
+			mutated, err := m.Mutate(mutable)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("Mutate() = %v, want error %t", err, tt.wantErr)
+			}
+			if mutated == tt.wantErr {
+				t.Errorf("Mutate() mutated = %t, want %t", mutated, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func testPod() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "pod", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app"}},
+		},
+	}}
+}
+
+func TestValidateResources(t *testing.T) {
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"initContainers": []interface{}{map[string]interface{}{
+				"name":      "init",
+				"resources": map[string]interface{}{"requests": map[string]interface{}{"ephemeral-storage": "1000m", "cpu": int64(1)}},
+			}},
+			"containers": []interface{}{map[string]interface{}{
+				"name":      "app",
+				"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+			}},
+		}}},
+	}}
+	errs := validateResources(deployment)
+	want := "spec.template.spec.initContainers[0].resources.requests[ephemeral-storage]"
+	if len(errs) != 1 || errs[0].Field != want {
+		t.Errorf("validateResources() = %v, want one error of %s", errs, want)
+	}
+}
@@ -0,0 +1,88 @@
+package mutators
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// parametersKey is the document under data holding template parameters.
+const parametersKey = "parameters"
+
+// ValidateDynamicTemplate returns an error if the rule or the parameter
+// schema of template is invalid.
+func ValidateDynamicTemplate(template *mutationsv1alpha1.DynamicTemplate) error {
+	if _, err := parametersValidator(template); err != nil {
+		return err
+	}
+	if _, err := rego.New(rego.Query(defaultRegoQuery), rego.Module(defaultRegoFileName, template.Spec.Rego)).PrepareForEval(context.Background()); err != nil {
+		return fmt.Errorf("preparing rego query: %w", err)
+	}
+	return nil
+}
+
+// parametersValidator returns the validator for the parameters of template,
+// or nil if it has no schema.
+func parametersValidator(template *mutationsv1alpha1.DynamicTemplate) (validation.SchemaValidator, error) {
+	if template.Spec.Parameters == nil {
+		return nil, nil
+	}
+	schema := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(template.Spec.Parameters, schema, nil); err != nil {
+		return nil, fmt.Errorf("converting parameters schema: %w", err)
+	}
+	validator, _, err := validation.NewSchemaValidator(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters schema: %w", err)
+	}
+	return validator, nil
+}
+
+// templateOptions returns the rego options instantiating the template
+// referenced by dynamic with its parameters, and the template revision.
+// The parameters are only stored as data.parameters: merged into input they
+// would end up in the output of rules deriving modified from input.
+func templateOptions(dynamic *mutationsv1alpha1.Dynamic, templates client.Reader) ([]func(*rego.Rego), string, error) {
+	template := &mutationsv1alpha1.DynamicTemplate{}
+	if err := templates.Get(context.Background(), client.ObjectKey{Name: dynamic.Spec.TemplateRef}, template); err != nil {
+		return nil, "", fmt.Errorf("getting DynamicTemplate %q: %w", dynamic.Spec.TemplateRef, err)
+	}
+	parameters, err := templateParameters(dynamic, template)
+	if err != nil {
+		return nil, "", err
+	}
+
+	options := []func(*rego.Rego){
+		rego.Module(defaultRegoFileName, template.Spec.Rego),
+		rego.Store(inmem.NewFromObject(map[string]interface{}{parametersKey: parameters})),
+	}
+	return options, fmt.Sprintf("%s/%d", template.UID, template.Generation), nil
+}
+
+// templateParameters returns the parameters of dynamic after validating them
+// against the schema of template.
+func templateParameters(dynamic *mutationsv1alpha1.Dynamic, template *mutationsv1alpha1.DynamicTemplate) (map[string]interface{}, error) {
+	parameters := map[string]interface{}{}
+	if dynamic.Spec.Parameters != nil {
+		if err := json.Unmarshal(dynamic.Spec.Parameters.Raw, &parameters); err != nil {
+			return nil, fmt.Errorf("parameters must be an object: %w", err)
+		}
+	}
+	validator, err := parametersValidator(template)
+	if err != nil {
+		return nil, fmt.Errorf("DynamicTemplate %q: %w", template.Name, err)
+	}
+	if errs := validation.ValidateCustomResource(field.NewPath("spec", "parameters"), parameters, validator); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return parameters, nil
+}
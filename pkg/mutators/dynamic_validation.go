@@ -0,0 +1,56 @@
+package mutators
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/opa/rego"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/bundles"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateDynamic returns an error if dynamic can not be turned into a
+// mutator. Unlike MutatorForDynamic it does not load bundles and accepts
+// references to missing templates, so that a Dynamic may be applied before
+// its sources.
+func ValidateDynamic(dynamic *mutationsv1alpha1.Dynamic, templates client.Reader) error {
+	if err := core.ValidateName(dynamic.Name); err != nil {
+		return err
+	}
+	sources := 0
+	for _, set := range []bool{dynamic.Spec.Rego != "", dynamic.Spec.Bundle != nil, dynamic.Spec.TemplateRef != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of rego, bundle and templateRef must be set")
+	}
+	if dynamic.Spec.Parameters != nil && dynamic.Spec.TemplateRef == "" {
+		return fmt.Errorf("parameters require templateRef")
+	}
+
+	switch {
+	case dynamic.Spec.Bundle != nil:
+		return bundles.ValidateSource(dynamic.Spec.Bundle)
+	case dynamic.Spec.TemplateRef != "":
+		template := &mutationsv1alpha1.DynamicTemplate{}
+		err := templates.Get(context.Background(), client.ObjectKey{Name: dynamic.Spec.TemplateRef}, template)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting DynamicTemplate %q: %w", dynamic.Spec.TemplateRef, err)
+		}
+		_, err = templateParameters(dynamic, template)
+		return err
+	default:
+		if _, err := rego.New(rego.Query(defaultRegoQuery), rego.Module(defaultRegoFileName, dynamic.Spec.Rego)).PrepareForEval(context.Background()); err != nil {
+			return fmt.Errorf("preparing rego query: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,267 @@
+package mutators
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var imageRewriteLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "imagerewrite")
+
+const defaultRegistry = "docker.io"
+
+// ImageRewriteMutator is a mutator object built out of an ImageRewrite instance.
+type ImageRewriteMutator struct {
+	id      types.ID
+	rewrite *mutationsv1alpha1.ImageRewrite
+	// regexes holds the compiled regex of each rule, nil for prefix rules.
+	regexes []*regexp.Regexp
+}
+
+// imageRewriteRecord is the value recorded per container in the
+// ImageRewritesAnnotation.
+type imageRewriteRecord struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ImageRewriteMutator implements mutator.
+var _ types.Mutator = &ImageRewriteMutator{}
+
+func (m *ImageRewriteMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if podSpecPath(mutable.Object) == nil {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.rewrite.Spec.Match, target)
+}
+
+func (m *ImageRewriteMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *ImageRewriteMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	specPath := podSpecPath(obj)
+	if specPath == nil {
+		return false, nil
+	}
+
+	records := make(map[string]imageRewriteRecord)
+	if value, ok := obj.GetAnnotations()[mutationsv1alpha1.ImageRewritesAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &records); err != nil {
+			imageRewriteLog.V(1).Info("Ignoring malformed annotation", "mutator", m.id, "annotation", mutationsv1alpha1.ImageRewritesAnnotation)
+			records = make(map[string]imageRewriteRecord)
+		}
+	}
+
+	mutated := false
+	for _, field := range containerFieldsFor(m.rewrite.Spec.ContainerTypes) {
+		containers := namedList(obj.Object, fieldPath(specPath, field)...)
+		changed := false
+		for _, container := range containers {
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			if image == "" {
+				continue
+			}
+			// Never rewrite an image twice; another rule could match the result.
+			if record, ok := records[name]; ok && record.To == image {
+				continue
+			}
+			rewritten, ok := m.rewriteImage(image)
+			if !ok || rewritten == image {
+				continue
+			}
+			container["image"] = rewritten
+			records[name] = imageRewriteRecord{From: image, To: rewritten}
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if err := setNamedList(obj.Object, containers, fieldPath(specPath, field)...); err != nil {
+			return false, err
+		}
+		mutated = true
+	}
+	if !mutated {
+		return false, nil
+	}
+
+	if err := m.addImagePullSecrets(obj, specPath); err != nil {
+		return false, err
+	}
+	value, err := json.Marshal(records)
+	if err != nil {
+		return false, err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[mutationsv1alpha1.ImageRewritesAnnotation] = string(value)
+	obj.SetAnnotations(annotations)
+	imageRewriteLog.V(4).Info("Rewrote images", "mutator", m.id, "rewrites", string(value))
+	return true, nil
+}
+
+// rewriteImage applies the first matching rule to image. It returns false if
+// no rule matched.
+func (m *ImageRewriteMutator) rewriteImage(image string) (string, bool) {
+	repository, suffix := splitImage(image)
+	normalized := normalizeRepository(repository)
+	for i, rule := range m.rewrite.Spec.Rules {
+		if re := m.regexes[i]; re != nil {
+			if re.MatchString(normalized) {
+				return re.ReplaceAllString(normalized, rule.Replacement) + suffix, true
+			}
+			continue
+		}
+		if strings.HasPrefix(normalized, rule.Prefix) {
+			return rule.Replacement + strings.TrimPrefix(normalized, rule.Prefix) + suffix, true
+		}
+	}
+	return "", false
+}
+
+func (m *ImageRewriteMutator) addImagePullSecrets(obj *unstructured.Unstructured, specPath []string) error {
+	if len(m.rewrite.Spec.ImagePullSecrets) == 0 {
+		return nil
+	}
+	secrets := namedList(obj.Object, fieldPath(specPath, "imagePullSecrets")...)
+	existing := names(secrets)
+	changed := false
+	for _, secret := range m.rewrite.Spec.ImagePullSecrets {
+		if existing[secret.Name] {
+			continue
+		}
+		secrets = append(secrets, map[string]interface{}{"name": secret.Name})
+		existing[secret.Name] = true
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return setNamedList(obj.Object, secrets, fieldPath(specPath, "imagePullSecrets")...)
+}
+
+func (m *ImageRewriteMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *ImageRewriteMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *ImageRewriteMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*ImageRewriteMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.rewrite.Spec, m.rewrite.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *ImageRewriteMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *ImageRewriteMutator) DeepCopy() types.Mutator {
+	// compiled regexes are safe for concurrent use and shared between copies.
+	res := &ImageRewriteMutator{
+		id:      m.id,
+		rewrite: m.rewrite.DeepCopy(),
+		regexes: m.regexes,
+	}
+	return res
+}
+
+func (m *ImageRewriteMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.rewrite.GetGeneration())
+}
+
+// MutatorForImageRewrite returns a mutator built from the given ImageRewrite instance.
+func MutatorForImageRewrite(rewrite *mutationsv1alpha1.ImageRewrite) (*ImageRewriteMutator, error) {
+	imageRewriteLog.V(1).Info("Creating mutator", "imagerewrite", rewrite)
+	if err := core.ValidateName(rewrite.Name); err != nil {
+		return nil, err
+	}
+	if len(rewrite.Spec.Rules) == 0 {
+		return nil, fmt.Errorf("at least one rule is required")
+	}
+	regexes := make([]*regexp.Regexp, len(rewrite.Spec.Rules))
+	for i, rule := range rewrite.Spec.Rules {
+		switch {
+		case rule.Prefix != "" && rule.Regex != "":
+			return nil, fmt.Errorf("rules[%d]: prefix and regex are mutually exclusive", i)
+		case rule.Regex != "":
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid regex: %w", i, err)
+			}
+			regexes[i] = re
+		case rule.Prefix == "":
+			return nil, fmt.Errorf("rules[%d]: one of prefix or regex is required", i)
+		}
+	}
+	// This is not always set by the kubernetes API server
+	rewrite.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "ImageRewrite"})
+	return &ImageRewriteMutator{
+		id:      types.MakeID(rewrite),
+		rewrite: rewrite.DeepCopy(),
+		regexes: regexes,
+	}, nil
+}
+
+// splitImage splits an image reference into its repository and the
+// remaining `:tag`, `@digest` or `:tag@digest` suffix.
+func splitImage(image string) (string, string) {
+	name, suffix := image, ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	// a colon before the last slash separates the registry host from its port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, suffix = name[:i], name[i:]+suffix
+	}
+	return name, suffix
+}
+
+// normalizeRepository returns the fully qualified form of repository, the
+// same way the container runtime resolves it.
+func normalizeRepository(repository string) string {
+	i := strings.Index(repository, "/")
+	if i < 0 {
+		return defaultRegistry + "/library/" + repository
+	}
+	host := repository[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return repository
+	}
+	return defaultRegistry + "/" + repository
+}
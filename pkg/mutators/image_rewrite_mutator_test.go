@@ -0,0 +1,131 @@
+package mutators
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image          string
+		wantRepository string
+		wantSuffix     string
+	}{{
+		image:          "nginx",
+		wantRepository: "nginx",
+	}, {
+		image:          "nginx:1.27",
+		wantRepository: "nginx",
+		wantSuffix:     ":1.27",
+	}, {
+		image:          "nginx@sha256:abc",
+		wantRepository: "nginx",
+		wantSuffix:     "@sha256:abc",
+	}, {
+		image:          "nginx:1.27@sha256:abc",
+		wantRepository: "nginx",
+		wantSuffix:     ":1.27@sha256:abc",
+	}, {
+		image:          "localhost:5000/app",
+		wantRepository: "localhost:5000/app",
+	}, {
+		image:          "localhost:5000/app:v1@sha256:abc",
+		wantRepository: "localhost:5000/app",
+		wantSuffix:     ":v1@sha256:abc",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			repository, suffix := splitImage(tt.image)
+			if repository != tt.wantRepository || suffix != tt.wantSuffix {
+				t.Errorf("splitImage() = %q, %q, want %q, %q", repository, suffix, tt.wantRepository, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestImageRewriteMutate(t *testing.T) {
+	tests := []struct {
+		name           string
+		rules          []mutationsv1alpha1.ImageRewriteRule
+		containerTypes []mutationsv1alpha1.ContainerType
+		obj            string
+		want           string
+	}{{
+		name:  "prefix",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Prefix: "docker.io/", Replacement: "mirror.io/"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"nginx:1.27"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"nginx:1.27\",\"to\":\"mirror.io/library/nginx:1.27\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"mirror.io/library/nginx:1.27"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		name:  "tag and digest are preserved",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Prefix: "localhost:5000/", Replacement: "registry.io/"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"localhost:5000/app:v1@sha256:abc"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"localhost:5000/app:v1@sha256:abc\",\"to\":\"registry.io/app:v1@sha256:abc\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"registry.io/app:v1@sha256:abc"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		name:  "regex with capture groups",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Regex: `^docker\.io/library/(.*)$`, Replacement: "mirror.io/$1"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"nginx@sha256:abc"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"nginx@sha256:abc\",\"to\":\"mirror.io/nginx@sha256:abc\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"mirror.io/nginx@sha256:abc"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		name:  "unanchored regex replaces every match",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Regex: `team-a`, Replacement: "team-b"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"quay.io/team-a/team-a-app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"quay.io/team-a/team-a-app\",\"to\":\"quay.io/team-b/team-b-app\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"quay.io/team-b/team-b-app"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		name: "first matching rule wins",
+		rules: []mutationsv1alpha1.ImageRewriteRule{
+			{Prefix: "quay.io/", Replacement: "quay.mirror.io/"},
+			{Regex: `.*`, Replacement: "other.io/image"},
+		},
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"quay.io/app:v1"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"quay.io/app:v1\",\"to\":\"quay.mirror.io/app:v1\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"quay.mirror.io/app:v1"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		// The rule matches its own output, which must not be rewritten again.
+		name:  "rewritten once",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Regex: `^(.*)$`, Replacement: "mirror.io/$1"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"quay.io/app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"app\":{\"from\":\"quay.io/app\",\"to\":\"mirror.io/quay.io/app\"}}"}},
+			"spec":{"containers":[{"name":"app","image":"mirror.io/quay.io/app"}],"imagePullSecrets":[{"name":"mirror"}]}}`,
+	}, {
+		name:  "no matching rule",
+		rules: []mutationsv1alpha1.ImageRewriteRule{{Prefix: "quay.io/", Replacement: "quay.mirror.io/"}},
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"nginx"}]}}`,
+		want:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"nginx"}]}}`,
+	}, {
+		name:           "container types of a pod template",
+		rules:          []mutationsv1alpha1.ImageRewriteRule{{Prefix: "docker.io/", Replacement: "mirror.io/"}},
+		containerTypes: []mutationsv1alpha1.ContainerType{mutationsv1alpha1.ContainerTypeInitContainers},
+		obj:            `{"apiVersion":"batch/v1","kind":"CronJob","spec":{"jobTemplate":{"spec":{"template":{"spec":{"initContainers":[{"name":"init","image":"busybox"}],"containers":[{"name":"app","image":"nginx"}]}}}}}}`,
+		want: `{"apiVersion":"batch/v1","kind":"CronJob","metadata":{"annotations":{"mutato.kubesphere.io/image-rewrites":"{\"init\":{\"from\":\"busybox\",\"to\":\"mirror.io/library/busybox\"}}"}},
+			"spec":{"jobTemplate":{"spec":{"template":{"spec":{"initContainers":[{"name":"init","image":"mirror.io/library/busybox"}],"containers":[{"name":"app","image":"nginx"}],"imagePullSecrets":[{"name":"mirror"}]}}}}}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := MutatorForImageRewrite(&mutationsv1alpha1.ImageRewrite{
+				ObjectMeta: metav1.ObjectMeta{Name: "mirror"},
+				Spec: mutationsv1alpha1.ImageRewriteSpec{
+					ContainerTypes:   tt.containerTypes,
+					Rules:            tt.rules,
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "mirror"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("MutatorForImageRewrite() = %v", err)
+			}
+			mutable := &types.Mutable{Object: object(t, tt.obj)}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package mutators
+
+import (
+	"regexp"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/wildcard"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// wildcardPattern is the pattern of wildcard.Wildcard enforced by the CRDs.
+var wildcardPattern = regexp.MustCompile(`^\*?[-:a-z0-9]*\*?$`)
+
+// ValidateMatch validates m. Kinds are looked up with mapper, if set.
+func ValidateMatch(m *match.Match, mapper meta.RESTMapper, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if m.Source != "" && !types.IsValidSource(types.SourceType(m.Source)) {
+		errs = append(errs, field.NotSupported(fldPath.Child("source"), m.Source,
+			[]string{string(types.SourceTypeAll), string(types.SourceTypeOriginal), string(types.SourceTypeGenerated)}))
+	}
+	switch m.Scope {
+	case "", "*", apiextensionsv1.ClusterScoped, apiextensionsv1.NamespaceScoped:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("scope"), m.Scope,
+			[]string{"*", string(apiextensionsv1.ClusterScoped), string(apiextensionsv1.NamespaceScoped)}))
+	}
+	errs = append(errs, validateWildcards(m.Namespaces, fldPath.Child("namespaces"))...)
+	errs = append(errs, validateWildcards(m.ExcludedNamespaces, fldPath.Child("excludedNamespaces"))...)
+	if m.Name != "" {
+		errs = append(errs, validateWildcards([]wildcard.Wildcard{m.Name}, fldPath.Child("name"))...)
+	}
+	opts := metav1validation.LabelSelectorValidationOptions{}
+	errs = append(errs, metav1validation.ValidateLabelSelector(m.LabelSelector, opts, fldPath.Child("labelSelector"))...)
+	errs = append(errs, metav1validation.ValidateLabelSelector(m.NamespaceSelector, opts, fldPath.Child("namespaceSelector"))...)
+	if mapper != nil {
+		errs = append(errs, validateKinds(m.Kinds, mapper, fldPath.Child("kinds"))...)
+	}
+	return errs
+}
+
+func validateWildcards(wildcards []wildcard.Wildcard, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, w := range wildcards {
+		if !wildcardPattern.MatchString(string(w)) {
+			errs = append(errs, field.Invalid(fldPath.Index(i), w,
+				"must consist of lower case alphanumeric characters, '-' or ':', with an optional leading or trailing '*'"))
+		}
+	}
+	return errs
+}
+
+// validateKinds rejects kinds that are not served by any of their groups.
+// Discovery errors other than unknown kinds are ignored, so that an
+// unavailable API does not block changes to unrelated rules.
+func validateKinds(kinds []match.Kinds, mapper meta.RESTMapper, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, k := range kinds {
+		// No apiGroups matches every group.
+		if len(k.APIGroups) == 0 || containsString(k.APIGroups, match.Wildcard) {
+			continue
+		}
+		for j, kind := range k.Kinds {
+			if kind == match.Wildcard {
+				continue
+			}
+			found := false
+			for _, group := range k.APIGroups {
+				_, err := mapper.RESTMappings(schema.GroupKind{Group: group, Kind: kind})
+				if err == nil || !meta.IsNoMatchError(err) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, field.Invalid(fldPath.Index(i).Child("kinds").Index(j), kind,
+					"kind is not served by any of the apiGroups"))
+			}
+		}
+	}
+	return errs
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package mutators
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/wildcard"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var metadataPropagationLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "metadatapropagation")
+
+// MetadataPropagationMutator is a mutator object built out of a
+// MetadataPropagation instance.
+type MetadataPropagationMutator struct {
+	id          types.ID
+	propagation *mutationsv1alpha1.MetadataPropagation
+	workspaces  WorkspaceGetter
+	workloads   WorkloadGetter
+}
+
+// MetadataPropagationMutator implements mutator.
+var _ types.Mutator = &MetadataPropagationMutator{}
+
+func (m *MetadataPropagationMutator) Matches(mutable *types.Mutable) (bool, error) {
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.propagation.Spec.Match, target)
+}
+
+func (m *MetadataPropagationMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *MetadataPropagationMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	objLabels := obj.GetLabels()
+	objAnnotations := obj.GetAnnotations()
+	labelsChanged, annotationsChanged := false, false
+
+	sources := map[mutationsv1alpha1.PropagationSource]metav1.Object{}
+	for _, rule := range m.propagation.Spec.Rules {
+		source, ok := sources[rule.From]
+		if !ok {
+			var err error
+			if source, err = m.sourceFor(mutable, rule.From); err != nil {
+				return false, err
+			}
+			sources[rule.From] = source
+		}
+		if source == nil {
+			continue
+		}
+		if objLabels, ok = propagate(objLabels, source.GetLabels(), &rule.Labels); ok {
+			labelsChanged = true
+		}
+		if objAnnotations, ok = propagate(objAnnotations, source.GetAnnotations(), &rule.Annotations); ok {
+			annotationsChanged = true
+		}
+	}
+
+	if labelsChanged {
+		obj.SetLabels(objLabels)
+	}
+	if annotationsChanged {
+		obj.SetAnnotations(objAnnotations)
+	}
+	mutated := labelsChanged || annotationsChanged
+	if mutated {
+		metadataPropagationLog.V(4).Info("Propagated metadata", "mutator", m.id)
+	}
+	return mutated, nil
+}
+
+// sourceFor returns the object metadata is copied from, or nil if the
+// object has no such source.
+func (m *MetadataPropagationMutator) sourceFor(mutable *types.Mutable, from mutationsv1alpha1.PropagationSource) (metav1.Object, error) {
+	switch from {
+	case mutationsv1alpha1.PropagationSourceNamespace:
+		if mutable.Namespace == nil {
+			return nil, nil
+		}
+		return mutable.Namespace, nil
+	case mutationsv1alpha1.PropagationSourceWorkspace:
+		if mutable.Namespace == nil || m.workspaces == nil {
+			return nil, nil
+		}
+		name := mutable.Namespace.GetLabels()[mutationsv1alpha1.WorkspaceLabel]
+		if name == "" {
+			return nil, nil
+		}
+		workspace, err := getWorkspace(m.workspaces, mutable, name)
+		if err != nil {
+			return nil, fmt.Errorf("getting workspace %q: %w", name, err)
+		}
+		if workspace == nil {
+			return nil, nil
+		}
+		return workspace, nil
+	case mutationsv1alpha1.PropagationSourceWorkload:
+		if m.workloads == nil {
+			return nil, nil
+		}
+		workload, err := getWorkload(m.workloads, mutable)
+		if err != nil {
+			return nil, fmt.Errorf("getting workload: %w", err)
+		}
+		if workload == nil {
+			return nil, nil
+		}
+		return workload, nil
+	default:
+		return nil, fmt.Errorf("unknown propagation source %q", from)
+	}
+}
+
+// propagate copies the entries of source selected by keys into target,
+// skipping keys target already has. It returns the possibly allocated
+// target and whether it changed.
+func propagate(target, source map[string]string, keys *mutationsv1alpha1.PropagatedKeys) (map[string]string, bool) {
+	if len(keys.Keys) == 0 || len(source) == 0 {
+		return target, false
+	}
+	sourceKeys := make([]string, 0, len(source))
+	for key := range source {
+		sourceKeys = append(sourceKeys, key)
+	}
+	sort.Strings(sourceKeys)
+
+	changed := false
+	for _, key := range sourceKeys {
+		if !selectsKey(keys.Keys, key) {
+			continue
+		}
+		to := key
+		if renamed, ok := keys.Rename[key]; ok {
+			to = renamed
+		}
+		if _, ok := target[to]; ok {
+			continue
+		}
+		if target == nil {
+			target = map[string]string{}
+		}
+		target[to] = source[key]
+		changed = true
+	}
+	return target, changed
+}
+
+func selectsKey(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if wildcard.Wildcard(pattern).Matches(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MetadataPropagationMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *MetadataPropagationMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *MetadataPropagationMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*MetadataPropagationMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.propagation.Spec, m.propagation.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *MetadataPropagationMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *MetadataPropagationMutator) DeepCopy() types.Mutator {
+	res := &MetadataPropagationMutator{
+		id:          m.id,
+		propagation: m.propagation.DeepCopy(),
+		workspaces:  m.workspaces,
+		workloads:   m.workloads,
+	}
+	return res
+}
+
+func (m *MetadataPropagationMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.propagation.GetGeneration())
+}
+
+// MutatorForMetadataPropagation returns a mutator built from the given
+// MetadataPropagation instance. workspaces and workloads may be nil, in which
+// case rules copying from those sources never apply.
+func MutatorForMetadataPropagation(propagation *mutationsv1alpha1.MetadataPropagation, workspaces WorkspaceGetter, workloads WorkloadGetter) (*MetadataPropagationMutator, error) {
+	metadataPropagationLog.V(1).Info("Creating mutator", "metadatapropagation", propagation)
+	if err := core.ValidateName(propagation.Name); err != nil {
+		return nil, err
+	}
+	for i, rule := range propagation.Spec.Rules {
+		if err := validatePropagatedKeys(&rule.Labels); err != nil {
+			return nil, fmt.Errorf("rules[%d].labels: %w", i, err)
+		}
+		if err := validatePropagatedKeys(&rule.Annotations); err != nil {
+			return nil, fmt.Errorf("rules[%d].annotations: %w", i, err)
+		}
+	}
+
+	// This is not always set by the kubernetes API server
+	propagation.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "MetadataPropagation"})
+	return &MetadataPropagationMutator{
+		id:          types.MakeID(propagation),
+		propagation: propagation.DeepCopy(),
+		workspaces:  workspaces,
+		workloads:   workloads,
+	}, nil
+}
+
+func validatePropagatedKeys(keys *mutationsv1alpha1.PropagatedKeys) error {
+	for _, key := range keys.Keys {
+		if key == "" || strings.Contains(strings.Trim(key, "*"), "*") {
+			return fmt.Errorf("invalid key %q: `*` is only allowed at the start or end", key)
+		}
+	}
+	for from, to := range keys.Rename {
+		if errs := validation.IsQualifiedName(to); len(errs) > 0 {
+			return fmt.Errorf("invalid rename of %q to %q: %s", from, to, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package mutators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// fakeWorkloads returns workload for every object with a controller and
+// counts the lookups.
+type fakeWorkloads struct {
+	workload *unstructured.Unstructured
+	gets     int
+}
+
+func (w *fakeWorkloads) GetWorkload(_ context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	w.gets++
+	if metav1.GetControllerOfNoCopy(obj) == nil {
+		return nil, nil
+	}
+	return w.workload.DeepCopy(), nil
+}
+
+// ownedPod is a pod controlled by a ReplicaSet.
+const ownedPod = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns","labels":{"team":"pod"},
+	"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"rs","uid":"rs-uid","controller":true}]}}`
+
+func TestMetadataPropagationMutate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []mutationsv1alpha1.PropagationRule
+		obj   string
+		want  string
+		// wantWorkspaceGets and wantWorkloadGets are the number of
+		// workspaces and workloads read.
+		wantWorkspaceGets int
+		wantWorkloadGets  int
+	}{{
+		name: "namespace",
+		rules: []mutationsv1alpha1.PropagationRule{{
+			From:        mutationsv1alpha1.PropagationSourceNamespace,
+			Labels:      mutationsv1alpha1.PropagatedKeys{Keys: []string{"cost-center"}},
+			Annotations: mutationsv1alpha1.PropagatedKeys{Keys: []string{"example.com/*"}, Rename: map[string]string{"example.com/owner": "owner"}},
+		}},
+		obj: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns"}}`,
+		want: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns","labels":{"cost-center":"ns-cost"},
+			"annotations":{"owner":"ns-owner","example.com/contact":"ns-contact"}}}`,
+	}, {
+		name: "workspace",
+		rules: []mutationsv1alpha1.PropagationRule{{
+			From:   mutationsv1alpha1.PropagationSourceWorkspace,
+			Labels: mutationsv1alpha1.PropagatedKeys{Keys: []string{"*"}},
+		}},
+		obj:               `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns"}}`,
+		want:              `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns","labels":{"cost-center":"ws-cost","tier":"gold"}}}`,
+		wantWorkspaceGets: 1,
+	}, {
+		name: "workload",
+		rules: []mutationsv1alpha1.PropagationRule{{
+			From:   mutationsv1alpha1.PropagationSourceWorkload,
+			Labels: mutationsv1alpha1.PropagatedKeys{Keys: []string{"app", "team"}},
+		}},
+		obj: ownedPod,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns","labels":{"team":"pod","app":"web"},
+			"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"rs","uid":"rs-uid","controller":true}]}}`,
+		wantWorkloadGets: 1,
+	}, {
+		name: "object without workload",
+		rules: []mutationsv1alpha1.PropagationRule{{
+			From:   mutationsv1alpha1.PropagationSourceWorkload,
+			Labels: mutationsv1alpha1.PropagatedKeys{Keys: []string{"*"}},
+		}},
+		obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns"}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns"}}`,
+	}, {
+		name: "first rule wins",
+		rules: []mutationsv1alpha1.PropagationRule{{
+			From:   mutationsv1alpha1.PropagationSourceWorkspace,
+			Labels: mutationsv1alpha1.PropagatedKeys{Keys: []string{"cost-center"}},
+		}, {
+			From:   mutationsv1alpha1.PropagationSourceNamespace,
+			Labels: mutationsv1alpha1.PropagatedKeys{Keys: []string{"cost-center"}},
+		}},
+		obj:               `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns"}}`,
+		want:              `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"ns","labels":{"cost-center":"ws-cost"}}}`,
+		wantWorkspaceGets: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspaces := &fakeWorkspaces{workspaces: map[string]map[string]string{"ws": {"cost-center": "ws-cost", "tier": "gold"}}}
+			workload := &unstructured.Unstructured{}
+			workload.SetLabels(map[string]string{"app": "web", "team": "web"})
+			workloads := &fakeWorkloads{workload: workload}
+			m, err := MutatorForMetadataPropagation(&mutationsv1alpha1.MetadataPropagation{
+				ObjectMeta: metav1.ObjectMeta{Name: "propagation"},
+				Spec:       mutationsv1alpha1.MetadataPropagationSpec{Rules: tt.rules},
+			}, workspaces, workloads)
+			if err != nil {
+				t.Fatalf("MutatorForMetadataPropagation() = %v", err)
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "ns",
+				Labels:      map[string]string{"cost-center": "ns-cost", mutationsv1alpha1.WorkspaceLabel: "ws"},
+				Annotations: map[string]string{"example.com/owner": "ns-owner", "example.com/contact": "ns-contact", "other": "ns-other"},
+			}}
+			mutable := &types.Mutable{Object: object(t, tt.obj), Namespace: ns}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+			// Both passes share the sources read for the request.
+			if workspaces.gets != tt.wantWorkspaceGets {
+				t.Errorf("workspaces read = %d, want %d", workspaces.gets, tt.wantWorkspaceGets)
+			}
+			if workloads.gets != tt.wantWorkloadGets {
+				t.Errorf("workloads read = %d, want %d", workloads.gets, tt.wantWorkloadGets)
+			}
+		})
+	}
+}
+
+func TestMutatorForMetadataPropagationInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		keys mutationsv1alpha1.PropagatedKeys
+	}{{
+		name: "wildcard in the middle",
+		keys: mutationsv1alpha1.PropagatedKeys{Keys: []string{"example.*.com"}},
+	}, {
+		name: "empty key",
+		keys: mutationsv1alpha1.PropagatedKeys{Keys: []string{""}},
+	}, {
+		name: "invalid rename",
+		keys: mutationsv1alpha1.PropagatedKeys{Keys: []string{"a"}, Rename: map[string]string{"a": "not a key"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MutatorForMetadataPropagation(&mutationsv1alpha1.MetadataPropagation{
+				ObjectMeta: metav1.ObjectMeta{Name: "propagation"},
+				Spec: mutationsv1alpha1.MetadataPropagationSpec{Rules: []mutationsv1alpha1.PropagationRule{{
+					From:   mutationsv1alpha1.PropagationSourceNamespace,
+					Labels: tt.keys,
+				}}},
+			}, nil, nil)
+			if err == nil {
+				t.Error("MutatorForMetadataPropagation() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package mutators
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var placementLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "placement")
+
+var nodeSelectorTermsPath = []string{"spec", "affinity", "nodeAffinity", "requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms"}
+
+// PlacementMutator is a mutator object built out of a Placement instance.
+type PlacementMutator struct {
+	id         types.ID
+	placement  *mutationsv1alpha1.Placement
+	workspaces WorkspaceGetter
+
+	requirements              []map[string]interface{}
+	tolerations               []map[string]interface{}
+	topologySpreadConstraints []map[string]interface{}
+}
+
+// placementValues are the fields available to Placement templates.
+type placementValues struct {
+	Namespace       string
+	NamespaceLabels map[string]string
+	Workspace       string
+	WorkspaceLabels map[string]string
+	Labels          map[string]string
+}
+
+// PlacementMutator implements mutator.
+var _ types.Mutator = &PlacementMutator{}
+
+func (m *PlacementMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if !isPod(mutable.Object) {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.placement.Spec.Match, target)
+}
+
+func (m *PlacementMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *PlacementMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	// The scheduling constraints of existing pods are immutable.
+	if obj.GetUID() != "" {
+		return false, nil
+	}
+
+	values, err := m.valuesFor(mutable)
+	if err != nil {
+		return false, err
+	}
+
+	mutated := false
+	for _, merge := range []func(*unstructured.Unstructured, *placementValues) (bool, error){
+		m.mergeNodeSelector,
+		m.mergeNodeAffinity,
+		m.mergeTolerations,
+		m.mergePriorityClassName,
+		m.mergeTopologySpreadConstraints,
+	} {
+		changed, err := merge(obj, values)
+		if err != nil {
+			return false, err
+		}
+		mutated = mutated || changed
+	}
+	if mutated {
+		placementLog.V(4).Info("Applied placement", "mutator", m.id, "workspace", values.Workspace)
+	}
+	return mutated, nil
+}
+
+func (m *PlacementMutator) valuesFor(mutable *types.Mutable) (*placementValues, error) {
+	values := &placementValues{
+		Namespace: mutable.Object.GetNamespace(),
+		Labels:    mutable.Object.GetLabels(),
+	}
+	if mutable.Namespace == nil {
+		return values, nil
+	}
+	values.NamespaceLabels = mutable.Namespace.GetLabels()
+	values.Workspace = values.NamespaceLabels[mutationsv1alpha1.WorkspaceLabel]
+	if values.Workspace == "" || m.workspaces == nil {
+		return values, nil
+	}
+	workspace, err := getWorkspace(m.workspaces, mutable, values.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("getting workspace %q: %w", values.Workspace, err)
+	}
+	if workspace != nil {
+		values.WorkspaceLabels = workspace.GetLabels()
+	}
+	return values, nil
+}
+
+func (m *PlacementMutator) mergeNodeSelector(obj *unstructured.Unstructured, values *placementValues) (bool, error) {
+	nodeSelector, _, err := unstructured.NestedStringMap(obj.Object, "spec", "nodeSelector")
+	if err != nil {
+		return false, err
+	}
+	if nodeSelector == nil {
+		nodeSelector = make(map[string]string)
+	}
+	changed := false
+	for key, value := range m.placement.Spec.NodeSelector {
+		if _, ok := nodeSelector[key]; ok {
+			continue
+		}
+		empty := false
+		rendered, err := renderValue(value, values, &empty)
+		if err != nil {
+			return false, fmt.Errorf("rendering nodeSelector %q: %w", key, err)
+		}
+		if empty || rendered == "" {
+			continue
+		}
+		nodeSelector[key] = rendered.(string)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, unstructured.SetNestedStringMap(obj.Object, nodeSelector, "spec", "nodeSelector")
+}
+
+func (m *PlacementMutator) mergeNodeAffinity(obj *unstructured.Unstructured, values *placementValues) (bool, error) {
+	requirements, err := renderItems(m.requirements, values)
+	if err != nil || len(requirements) == 0 {
+		return false, err
+	}
+	terms := namedList(obj.Object, nodeSelectorTermsPath...)
+	if len(terms) == 0 {
+		terms = []map[string]interface{}{{}}
+	}
+	changed := false
+	// Requirements within a term are AND-ed, terms are OR-ed, so the new
+	// requirements are added to every term.
+	for _, term := range terms {
+		expressions := namedList(term, "matchExpressions")
+		added := false
+		for _, requirement := range requirements {
+			if containsItem(expressions, requirement) {
+				continue
+			}
+			expressions = append(expressions, requirement)
+			added = true
+		}
+		if !added {
+			continue
+		}
+		if err := setNamedList(term, expressions, "matchExpressions"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, setNamedList(obj.Object, terms, nodeSelectorTermsPath...)
+}
+
+func (m *PlacementMutator) mergeTolerations(obj *unstructured.Unstructured, values *placementValues) (bool, error) {
+	rendered, err := renderItems(m.tolerations, values)
+	if err != nil || len(rendered) == 0 {
+		return false, err
+	}
+	existing := namedList(obj.Object, "spec", "tolerations")
+	typed := make([]corev1.Toleration, len(existing))
+	for i := range existing {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existing[i], &typed[i]); err != nil {
+			return false, fmt.Errorf("invalid toleration: %w", err)
+		}
+	}
+	changed := false
+	for _, item := range rendered {
+		toleration := corev1.Toleration{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item, &toleration); err != nil {
+			return false, fmt.Errorf("invalid toleration: %w", err)
+		}
+		if containsToleration(typed, &toleration) {
+			continue
+		}
+		typed = append(typed, toleration)
+		existing = append(existing, item)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, setNamedList(obj.Object, existing, "spec", "tolerations")
+}
+
+func (m *PlacementMutator) mergePriorityClassName(obj *unstructured.Unstructured, values *placementValues) (bool, error) {
+	if m.placement.Spec.PriorityClassName == "" {
+		return false, nil
+	}
+	if current, _, _ := unstructured.NestedString(obj.Object, "spec", "priorityClassName"); current != "" {
+		return false, nil
+	}
+	rendered, err := renderValue(m.placement.Spec.PriorityClassName, values, nil)
+	if err != nil {
+		return false, fmt.Errorf("rendering priorityClassName: %w", err)
+	}
+	if rendered == "" {
+		return false, nil
+	}
+	return true, unstructured.SetNestedField(obj.Object, rendered, "spec", "priorityClassName")
+}
+
+func (m *PlacementMutator) mergeTopologySpreadConstraints(obj *unstructured.Unstructured, values *placementValues) (bool, error) {
+	rendered, err := renderItems(m.topologySpreadConstraints, values)
+	if err != nil || len(rendered) == 0 {
+		return false, err
+	}
+	existing := namedList(obj.Object, "spec", "topologySpreadConstraints")
+	key := func(item map[string]interface{}) string {
+		return fmt.Sprintf("%v/%v", item["topologyKey"], item["whenUnsatisfiable"])
+	}
+	keys := make(map[string]bool)
+	for _, item := range existing {
+		keys[key(item)] = true
+	}
+	changed := false
+	for _, item := range rendered {
+		if keys[key(item)] {
+			continue
+		}
+		keys[key(item)] = true
+		existing = append(existing, item)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, setNamedList(obj.Object, existing, "spec", "topologySpreadConstraints")
+}
+
+func (m *PlacementMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *PlacementMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *PlacementMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*PlacementMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.placement.Spec, m.placement.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *PlacementMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *PlacementMutator) DeepCopy() types.Mutator {
+	// the templates are never modified after construction, so they are
+	// shared between copies.
+	res := &PlacementMutator{
+		id:                        m.id,
+		placement:                 m.placement.DeepCopy(),
+		workspaces:                m.workspaces,
+		requirements:              m.requirements,
+		tolerations:               m.tolerations,
+		topologySpreadConstraints: m.topologySpreadConstraints,
+	}
+	return res
+}
+
+func (m *PlacementMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.placement.GetGeneration())
+}
+
+// MutatorForPlacement returns a mutator built from the given Placement instance.
+// workspaces may be nil, in which case workspace labels are never available.
+func MutatorForPlacement(placement *mutationsv1alpha1.Placement, workspaces WorkspaceGetter) (*PlacementMutator, error) {
+	placementLog.V(1).Info("Creating mutator", "placement", placement)
+	if err := core.ValidateName(placement.Name); err != nil {
+		return nil, err
+	}
+	for key, value := range placement.Spec.NodeSelector {
+		if _, err := renderValue(value, &placementValues{}, nil); err != nil {
+			return nil, fmt.Errorf("nodeSelector %q: %w", key, err)
+		}
+	}
+	if _, err := renderValue(placement.Spec.PriorityClassName, &placementValues{}, nil); err != nil {
+		return nil, fmt.Errorf("priorityClassName: %w", err)
+	}
+	requirements, err := placementTemplates("requiredNodeAffinity", placement.Spec.RequiredNodeAffinity)
+	if err != nil {
+		return nil, err
+	}
+	tolerations, err := placementTemplates("tolerations", placement.Spec.Tolerations)
+	if err != nil {
+		return nil, err
+	}
+	constraints, err := placementTemplates("topologySpreadConstraints", placement.Spec.TopologySpreadConstraints)
+	if err != nil {
+		return nil, err
+	}
+	// This is not always set by the kubernetes API server
+	placement.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "Placement"})
+	return &PlacementMutator{
+		id:                        types.MakeID(placement),
+		placement:                 placement.DeepCopy(),
+		workspaces:                workspaces,
+		requirements:              requirements,
+		tolerations:               tolerations,
+		topologySpreadConstraints: constraints,
+	}, nil
+}
+
+// placementTemplates converts items into unstructured templates and verifies
+// that all embedded templates parse.
+func placementTemplates[T any](field string, items []T) ([]map[string]interface{}, error) {
+	result, err := toUnstructuredList(items)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	for i, item := range result {
+		if _, err := renderTemplate(item, &placementValues{}); err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", field, i, err)
+		}
+	}
+	return result, nil
+}
+
+// renderItems renders every item, dropping the ones where a template renders
+// to an empty string.
+func renderItems(items []map[string]interface{}, values interface{}) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	for _, item := range items {
+		empty := false
+		rendered, err := renderValue(runtime.DeepCopyJSONValue(item), values, &empty)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			result = append(result, rendered.(map[string]interface{}))
+		}
+	}
+	return result, nil
+}
+
+func containsItem(list []map[string]interface{}, item map[string]interface{}) bool {
+	for _, existing := range list {
+		if reflect.DeepEqual(existing, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsToleration(list []corev1.Toleration, toleration *corev1.Toleration) bool {
+	for i := range list {
+		if list[i].MatchToleration(toleration) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package mutators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+// fakeWorkspaces counts the workspaces read.
+type fakeWorkspaces struct {
+	workspaces map[string]map[string]string
+	gets       int
+}
+
+func (w *fakeWorkspaces) GetWorkspace(_ context.Context, name string) (*unstructured.Unstructured, error) {
+	w.gets++
+	labels, ok := w.workspaces[name]
+	if !ok {
+		return nil, nil
+	}
+	workspace := &unstructured.Unstructured{}
+	workspace.SetGroupVersionKind(workspaceGVK)
+	workspace.SetName(name)
+	workspace.SetLabels(labels)
+	return workspace, nil
+}
+
+func TestPlacementMutate(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      mutationsv1alpha1.PlacementSpec
+		workspace string
+		obj       string
+		want      string
+		// wantGets is the number of workspaces read.
+		wantGets int
+	}{{
+		name:      "workspace labels",
+		spec:      mutationsv1alpha1.PlacementSpec{NodeSelector: map[string]string{"node-pool": `{{ index .WorkspaceLabels "node-pool" }}`}},
+		workspace: "gpu",
+		obj:       `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want:      `{"apiVersion":"v1","kind":"Pod","spec":{"nodeSelector":{"node-pool":"gpu"},"containers":[{"name":"app"}]}}`,
+		wantGets:  1,
+	}, {
+		name:      "empty values are skipped",
+		spec:      mutationsv1alpha1.PlacementSpec{NodeSelector: map[string]string{"node-pool": `{{ index .WorkspaceLabels "node-pool" }}`}},
+		workspace: "missing",
+		obj:       `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want:      `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		wantGets:  1,
+	}, {
+		name: "node selector keys of the pod are kept",
+		spec: mutationsv1alpha1.PlacementSpec{NodeSelector: map[string]string{"zone": "a", "disk": "ssd"}},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"nodeSelector":{"zone":"b"}}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"nodeSelector":{"zone":"b","disk":"ssd"}}}`,
+	}, {
+		name: "node affinity is AND-ed into every term",
+		spec: mutationsv1alpha1.PlacementSpec{RequiredNodeAffinity: []corev1.NodeSelectorRequirement{
+			{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"{{ .Namespace }}"}},
+		}},
+		obj: `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns"},"spec":{"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[
+			{"matchExpressions":[{"key":"zone","operator":"In","values":["a"]}]},
+			{"matchExpressions":[{"key":"pool","operator":"In","values":["ns"]}]}]}}}}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns"},"spec":{"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[
+			{"matchExpressions":[{"key":"zone","operator":"In","values":["a"]},{"key":"pool","operator":"In","values":["ns"]}]},
+			{"matchExpressions":[{"key":"pool","operator":"In","values":["ns"]}]}]}}}}}`,
+	}, {
+		name: "node affinity term is added",
+		spec: mutationsv1alpha1.PlacementSpec{RequiredNodeAffinity: []corev1.NodeSelectorRequirement{
+			{Key: "pool", Operator: corev1.NodeSelectorOpExists},
+		}},
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[
+			{"matchExpressions":[{"key":"pool","operator":"Exists"}]}]}}}}}`,
+	}, {
+		name: "tolerations are deduplicated",
+		spec: mutationsv1alpha1.PlacementSpec{Tolerations: []corev1.Toleration{
+			{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+			{Key: "spot", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoExecute},
+		}},
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{"tolerations":[{"key":"gpu","operator":"Exists","effect":"NoSchedule","tolerationSeconds":60}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"tolerations":[{"key":"gpu","operator":"Exists","effect":"NoSchedule","tolerationSeconds":60},
+			{"key":"spot","operator":"Equal","value":"true","effect":"NoExecute"}]}}`,
+	}, {
+		name: "priority class and topology spread constraints",
+		spec: mutationsv1alpha1.PlacementSpec{
+			PriorityClassName: "{{ .Namespace }}-priority",
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+				{MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: corev1.DoNotSchedule},
+				{MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+			},
+		},
+		obj: `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns"},"spec":{"topologySpreadConstraints":[{"maxSkew":2,"topologyKey":"zone","whenUnsatisfiable":"DoNotSchedule"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns"},"spec":{"priorityClassName":"ns-priority","topologySpreadConstraints":[
+			{"maxSkew":2,"topologyKey":"zone","whenUnsatisfiable":"DoNotSchedule"},{"maxSkew":1,"topologyKey":"zone","whenUnsatisfiable":"ScheduleAnyway"}]}}`,
+	}, {
+		name:      "existing pod",
+		spec:      mutationsv1alpha1.PlacementSpec{PriorityClassName: "high"},
+		workspace: "gpu",
+		obj:       `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{}}`,
+		want:      `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspaces := &fakeWorkspaces{workspaces: map[string]map[string]string{"gpu": {"node-pool": "gpu"}}}
+			m, err := MutatorForPlacement(&mutationsv1alpha1.Placement{
+				ObjectMeta: metav1.ObjectMeta{Name: "placement"},
+				Spec:       tt.spec,
+			}, workspaces)
+			if err != nil {
+				t.Fatalf("MutatorForPlacement() = %v", err)
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+			if tt.workspace != "" {
+				ns.Labels = map[string]string{mutationsv1alpha1.WorkspaceLabel: tt.workspace}
+			}
+			mutable := &types.Mutable{Object: object(t, tt.obj), Namespace: ns}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+			// Both passes share the workspace read for the request.
+			if workspaces.gets != tt.wantGets {
+				t.Errorf("workspaces read = %d, want %d", workspaces.gets, tt.wantGets)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package mutators
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+const (
+	containersField          = "containers"
+	initContainersField      = "initContainers"
+	ephemeralContainersField = "ephemeralContainers"
+	volumesField             = "volumes"
+)
+
+// isPod returns true if obj is a core/v1 Pod.
+func isPod(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Pod"
+}
+
+// podSpecPath returns the path to the pod spec embedded in obj, or nil if obj
+// is neither a Pod nor an object with a pod template.
+func podSpecPath(obj *unstructured.Unstructured) []string {
+	gvk := obj.GroupVersionKind()
+	switch gvk.GroupKind() {
+	case schema.GroupKind{Kind: "Pod"}:
+		return []string{"spec"}
+	case schema.GroupKind{Kind: "PodTemplate"}:
+		return []string{"template", "spec"}
+	case schema.GroupKind{Kind: "ReplicationController"},
+		schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"},
+		schema.GroupKind{Group: "apps", Kind: "DaemonSet"},
+		schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return []string{"spec", "template", "spec"}
+	case schema.GroupKind{Group: "batch", Kind: "CronJob"}:
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+}
+
+// fieldPath appends fields to a copy of path.
+func fieldPath(path []string, fields ...string) []string {
+	return append(append(make([]string, 0, len(path)+len(fields)), path...), fields...)
+}
+
+// namedList returns the list stored at obj[field...] as a slice of maps.
+// Entries that are not objects are skipped.
+func namedList(obj map[string]interface{}, fields ...string) []map[string]interface{} {
+	list, found, err := unstructured.NestedSlice(obj, fields...)
+	if !found || err != nil {
+		return nil
+	}
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// names returns the set of `name` values in the given list entries.
+func names(items ...[]map[string]interface{}) map[string]bool {
+	result := make(map[string]bool)
+	for _, list := range items {
+		for _, item := range list {
+			if name, ok := item["name"].(string); ok {
+				result[name] = true
+			}
+		}
+	}
+	return result
+}
+
+// setNamedList stores items at obj[field...].
+func setNamedList(obj map[string]interface{}, items []map[string]interface{}, fields ...string) error {
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	return unstructured.SetNestedSlice(obj, list, fields...)
+}
+
+// toUnstructuredList converts typed API objects into their unstructured form.
+func toUnstructuredList[T any](items []T) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, len(items))
+	for i := range items {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// containerFields maps container types to their pod spec field names.
+var containerFields = map[mutationsv1alpha1.ContainerType]string{
+	mutationsv1alpha1.ContainerTypeContainers:          containersField,
+	mutationsv1alpha1.ContainerTypeInitContainers:      initContainersField,
+	mutationsv1alpha1.ContainerTypeEphemeralContainers: ephemeralContainersField,
+}
+
+// allContainerTypes is used when a spec does not limit the container types.
+var allContainerTypes = []mutationsv1alpha1.ContainerType{
+	mutationsv1alpha1.ContainerTypeInitContainers,
+	mutationsv1alpha1.ContainerTypeContainers,
+	mutationsv1alpha1.ContainerTypeEphemeralContainers,
+}
+
+// containerFieldsFor returns the pod spec fields for the given container
+// types, or for all of them if none are given.
+func containerFieldsFor(containerTypes []mutationsv1alpha1.ContainerType) []string {
+	if len(containerTypes) == 0 {
+		containerTypes = allContainerTypes
+	}
+	fields := make([]string, 0, len(containerTypes))
+	for _, t := range containerTypes {
+		fields = append(fields, containerFields[t])
+	}
+	return fields
+}
+
+// validateResources returns the errors of the container resources of obj.
+// OpenAPI types quantities as plain strings, so schema validation accepts
+// values the API server fails to decode, or byte counts such as memory in
+// thousandths of a byte.
+func validateResources(obj *unstructured.Unstructured) field.ErrorList {
+	specPath := podSpecPath(obj)
+	if specPath == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	for _, containerField := range []string{initContainersField, containersField, ephemeralContainersField} {
+		fldPath := field.NewPath(specPath[0], specPath[1:]...).Child(containerField)
+		for i, container := range namedList(obj.Object, fieldPath(specPath, containerField)...) {
+			for _, list := range []string{"limits", "requests"} {
+				values, _, _ := unstructured.NestedMap(container, "resources", list)
+				for name, value := range values {
+					errs = append(errs, validateQuantity(fldPath.Index(i).Child("resources", list).Key(name), corev1.ResourceName(name), value)...)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validateQuantity returns the errors of the quantity of the named resource.
+// Resources counted in bytes must be whole bytes without the milli suffix.
+func validateQuantity(fldPath *field.Path, name corev1.ResourceName, value interface{}) field.ErrorList {
+	s, ok := value.(string)
+	if !ok {
+		// Numbers are valid quantities, other types are schema errors.
+		return nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, s, err.Error())}
+	}
+	if isByteResource(name) && (strings.HasSuffix(s, "m") || q.MilliValue()%1000 != 0) {
+		return field.ErrorList{field.Invalid(fldPath, s, "must be a whole number of bytes")}
+	}
+	return nil
+}
+
+// isByteResource returns true if the quantities of name count bytes.
+func isByteResource(name corev1.ResourceName) bool {
+	return name == corev1.ResourceMemory || name == corev1.ResourceEphemeralStorage ||
+		strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix)
+}
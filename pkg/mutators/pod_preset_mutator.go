@@ -0,0 +1,301 @@
+package mutators
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/report"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var podPresetLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "podpreset")
+
+// PodPresetMutator is a mutator object built out of a PodPreset instance.
+type PodPresetMutator struct {
+	id       types.ID
+	preset   *mutationsv1alpha1.PodPreset
+	selector labels.Selector
+
+	env          []map[string]interface{}
+	envFrom      []map[string]interface{}
+	volumes      []map[string]interface{}
+	volumeMounts []map[string]interface{}
+}
+
+// PodPresetMutator implements mutator.
+var _ types.Mutator = &PodPresetMutator{}
+
+func (m *PodPresetMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if !isPod(mutable.Object) || mutable.Object.GetNamespace() != m.preset.Namespace {
+		return false, nil
+	}
+	if !m.selector.Matches(labels.Set(mutable.Object.GetLabels())) {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.preset.Spec.Match, target)
+}
+
+func (m *PodPresetMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *PodPresetMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	// The containers of existing pods are immutable.
+	if obj.GetUID() != "" {
+		return false, nil
+	}
+
+	r := report.For(mutable)
+	mutated, selected := false, false
+	for _, field := range containerFieldsFor(m.preset.Spec.ContainerTypes) {
+		containers := namedList(obj.Object, "spec", field)
+		changed := false
+		for _, container := range containers {
+			if !m.selectsContainer(container) {
+				continue
+			}
+			selected = true
+			name := container["name"]
+			for _, merge := range []struct {
+				field string
+				items []map[string]interface{}
+				key   func(map[string]interface{}) interface{}
+			}{
+				{"env", m.env, nameKey},
+				{"envFrom", m.envFrom, nil},
+				{"volumeMounts", m.volumeMounts, mountPathKey},
+			} {
+				prefix := fmt.Sprintf("%s/%v/", field, name)
+				c, err := m.mergeList(r, prefix, container, merge.field, merge.items, merge.key)
+				if err != nil {
+					return false, fmt.Errorf("%s %q: %w", field, name, err)
+				}
+				changed = changed || c
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := setNamedList(obj.Object, containers, "spec", field); err != nil {
+			return false, err
+		}
+		mutated = true
+	}
+
+	// Volumes are only needed by the selected containers.
+	if !selected {
+		return mutated, nil
+	}
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	changed, err := m.mergeList(r, "", spec, volumesField, m.volumes, nameKey)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		if err := unstructured.SetNestedMap(obj.Object, spec, "spec"); err != nil {
+			return false, err
+		}
+		mutated = true
+	}
+
+	if mutated {
+		podPresetLog.V(4).Info("Applied pod preset", "mutator", m.id)
+	}
+	return mutated, nil
+}
+
+// selectsContainer returns true if container matches any of the selectors.
+func (m *PodPresetMutator) selectsContainer(container map[string]interface{}) bool {
+	if len(m.preset.Spec.Containers) == 0 {
+		return true
+	}
+	name, _ := container["name"].(string)
+	image, _ := container["image"].(string)
+	for _, selector := range m.preset.Spec.Containers {
+		if patternMatches(selector.Name, name) && patternMatches(selector.Image, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeList merges items into the list at obj[field]. Items are identified by
+// key; a nil key identifies them by their whole content, so they never conflict.
+// Injected entries are claimed in r under prefix, field and key, and are not
+// overridden by other presets: the first preset evaluated wins.
+func (m *PodPresetMutator) mergeList(r *report.Report, prefix string, obj map[string]interface{}, field string, items []map[string]interface{}, key func(map[string]interface{}) interface{}) (bool, error) {
+	if len(items) == 0 {
+		return false, nil
+	}
+	list := namedList(obj, field)
+	changed := false
+	for _, item := range items {
+		index := -1
+		for i, existing := range list {
+			if key == nil && reflect.DeepEqual(existing, item) ||
+				key != nil && key(existing) == key(item) {
+				index = i
+				break
+			}
+		}
+		claim := func() bool {
+			return key == nil || r.Claim(fmt.Sprintf("%s%s/%v", prefix, field, key(item)), m.id) == m.id
+		}
+		switch {
+		case index < 0:
+			claim()
+			list = append(list, runtime.DeepCopyJSON(item))
+			changed = true
+		case reflect.DeepEqual(list[index], item):
+			// already injected
+		case m.preset.Spec.ConflictStrategy == mutationsv1alpha1.ConflictStrategyOverride && claim():
+			list[index] = runtime.DeepCopyJSON(item)
+			changed = true
+		case m.preset.Spec.ConflictStrategy == mutationsv1alpha1.ConflictStrategyFail:
+			return false, fmt.Errorf("%s %v conflicts with PodPreset %s/%s", field, key(item), m.preset.Namespace, m.preset.Name)
+		default:
+			podPresetLog.V(4).Info("Skipping conflicting entry", "mutator", m.id, "field", field, "key", key(item))
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, setNamedList(obj, list, field)
+}
+
+func (m *PodPresetMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *PodPresetMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *PodPresetMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*PodPresetMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.preset.Spec, m.preset.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *PodPresetMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *PodPresetMutator) DeepCopy() types.Mutator {
+	// the converted entries are never modified after construction, so they
+	// are shared between copies.
+	res := &PodPresetMutator{
+		id:           m.id,
+		preset:       m.preset.DeepCopy(),
+		selector:     m.selector,
+		env:          m.env,
+		envFrom:      m.envFrom,
+		volumes:      m.volumes,
+		volumeMounts: m.volumeMounts,
+	}
+	return res
+}
+
+func (m *PodPresetMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.preset.GetGeneration())
+}
+
+// MutatorForPodPreset returns a mutator built from the given PodPreset instance.
+func MutatorForPodPreset(preset *mutationsv1alpha1.PodPreset) (*PodPresetMutator, error) {
+	podPresetLog.V(1).Info("Creating mutator", "podpreset", preset)
+	if err := core.ValidateName(preset.Name); err != nil {
+		return nil, err
+	}
+
+	selector := labels.Everything()
+	if preset.Spec.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(preset.Spec.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+	for i, s := range preset.Spec.Containers {
+		if _, err := path.Match(s.Name, ""); err != nil {
+			return nil, fmt.Errorf("containers[%d].name: %w", i, err)
+		}
+		if _, err := path.Match(s.Image, ""); err != nil {
+			return nil, fmt.Errorf("containers[%d].image: %w", i, err)
+		}
+	}
+
+	env, err := toUnstructuredList(preset.Spec.Env)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env: %w", err)
+	}
+	envFrom, err := toUnstructuredList(preset.Spec.EnvFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envFrom: %w", err)
+	}
+	volumes, err := toUnstructuredList(preset.Spec.Volumes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volumes: %w", err)
+	}
+	volumeMounts, err := toUnstructuredList(preset.Spec.VolumeMounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volumeMounts: %w", err)
+	}
+
+	// This is not always set by the kubernetes API server
+	preset.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "PodPreset"})
+	return &PodPresetMutator{
+		id:           types.MakeID(preset),
+		preset:       preset.DeepCopy(),
+		selector:     selector,
+		env:          env,
+		envFrom:      envFrom,
+		volumes:      volumes,
+		volumeMounts: volumeMounts,
+	}, nil
+}
+
+func nameKey(item map[string]interface{}) interface{} {
+	return item["name"]
+}
+
+func mountPathKey(item map[string]interface{}) interface{} {
+	return item["mountPath"]
+}
+
+// patternMatches returns true if pattern is empty or matches s.
+func patternMatches(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, s)
+	return matched
+}
@@ -0,0 +1,156 @@
+package mutators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/report"
+)
+
+func newPodPreset(t *testing.T, name string, spec mutationsv1alpha1.PodPresetSpec) *PodPresetMutator {
+	t.Helper()
+	m, err := MutatorForPodPreset(&mutationsv1alpha1.PodPreset{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec:       spec,
+	})
+	if err != nil {
+		t.Fatalf("MutatorForPodPreset() = %v", err)
+	}
+	return m
+}
+
+func TestPodPresetMutate(t *testing.T) {
+	spec := mutationsv1alpha1.PodPresetSpec{
+		Env:          []corev1.EnvVar{{Name: "MODE", Value: "preset"}},
+		EnvFrom:      []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/config"}},
+		Volumes: []corev1.Volume{{
+			Name:         "config",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}},
+		}},
+	}
+	withStrategy := func(strategy mutationsv1alpha1.ConflictStrategy) mutationsv1alpha1.PodPresetSpec {
+		s := *spec.DeepCopy()
+		s.ConflictStrategy = strategy
+		return s
+	}
+	withContainers := func(selectors ...mutationsv1alpha1.ContainerSelector) mutationsv1alpha1.PodPresetSpec {
+		s := *spec.DeepCopy()
+		s.Containers = selectors
+		return s
+	}
+	tests := []struct {
+		name    string
+		spec    mutationsv1alpha1.PodPresetSpec
+		obj     string
+		want    string
+		wantErr bool
+	}{{
+		name: "inject",
+		spec: spec,
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"OTHER","value":"1"}]}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"OTHER","value":"1"},{"name":"MODE","value":"preset"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","configMap":{"name":"config"}}]}}`,
+	}, {
+		name: "skip conflicts",
+		spec: spec,
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"pod"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"other","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","emptyDir":{}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"pod"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"other","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","emptyDir":{}}]}}`,
+	}, {
+		name: "override conflicts",
+		spec: withStrategy(mutationsv1alpha1.ConflictStrategyOverride),
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"pod"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"other","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","emptyDir":{}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"preset"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","configMap":{"name":"config"}}]}}`,
+	}, {
+		name:    "fail on conflicts",
+		spec:    withStrategy(mutationsv1alpha1.ConflictStrategyFail),
+		obj:     `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"pod"}]}]}}`,
+		wantErr: true,
+	}, {
+		name: "identical entries do not conflict",
+		spec: withStrategy(mutationsv1alpha1.ConflictStrategyFail),
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"preset"}]}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"preset"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"}]}],
+			"volumes":[{"name":"config","configMap":{"name":"config"}}]}}`,
+	}, {
+		name: "selected containers",
+		spec: withContainers(mutationsv1alpha1.ContainerSelector{Image: "*/app:*"}),
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"registry.io/app:v1"},{"name":"proxy","image":"registry.io/proxy:v1"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","image":"registry.io/app:v1","env":[{"name":"MODE","value":"preset"}],
+			"envFrom":[{"configMapRef":{"name":"config"}}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"}]},{"name":"proxy","image":"registry.io/proxy:v1"}],
+			"volumes":[{"name":"config","configMap":{"name":"config"}}]}}`,
+	}, {
+		name: "volumes of unselected pods",
+		spec: withContainers(mutationsv1alpha1.ContainerSelector{Name: "app"}),
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"proxy"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"proxy"}]}}`,
+	}, {
+		name: "existing pod",
+		spec: spec,
+		obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPodPreset(t, "preset", tt.spec)
+			mutable := &types.Mutable{Object: object(t, tt.obj)}
+			if tt.wantErr {
+				if _, err := m.Mutate(mutable); err == nil {
+					t.Error("Mutate() succeeded, want error")
+				}
+				return
+			}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+		})
+	}
+}
+
+// TestPodPresetOverrideConverges checks that two presets overriding the same
+// entries converge on the first one evaluated instead of overriding each
+// other until the mutation system gives up.
+func TestPodPresetOverrideConverges(t *testing.T) {
+	presets := make([]*PodPresetMutator, 0, 2)
+	for _, value := range []string{"a", "b"} {
+		presets = append(presets, newPodPreset(t, value, mutationsv1alpha1.PodPresetSpec{
+			Env:              []corev1.EnvVar{{Name: "MODE", Value: value}},
+			ConflictStrategy: mutationsv1alpha1.ConflictStrategyOverride,
+		}))
+	}
+	mutable := &types.Mutable{Object: object(t, `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"pod"}]}]}}`)}
+	report.Begin(context.Background(), mutable)
+	defer report.End(mutable)
+
+	converged := false
+	for pass := 0; pass < 3 && !converged; pass++ {
+		converged = true
+		for _, m := range presets {
+			mutated, err := m.Mutate(mutable)
+			if err != nil {
+				t.Fatalf("Mutate() = %v", err)
+			}
+			converged = converged && !mutated
+		}
+	}
+	if !converged {
+		t.Fatal("presets did not converge")
+	}
+	checkObject(t, `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","env":[{"name":"MODE","value":"a"}]}]}}`, mutable.Object)
+}
@@ -0,0 +1,271 @@
+package mutators
+
+import (
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/report"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var podSecurityLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "podsecuritydefault")
+
+// PodSecurityDefaultMutator is a mutator object built out of a PodSecurityDefault instance.
+type PodSecurityDefaultMutator struct {
+	id       types.ID
+	defaults *mutationsv1alpha1.PodSecurityDefault
+}
+
+// PodSecurityDefaultMutator implements mutator.
+var _ types.Mutator = &PodSecurityDefaultMutator{}
+
+func (m *PodSecurityDefaultMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if podSpecPath(mutable.Object) == nil {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.defaults.Spec.Match, target)
+}
+
+func (m *PodSecurityDefaultMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *PodSecurityDefaultMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	specPath := podSpecPath(obj)
+	// The security context of existing pods is immutable.
+	if specPath == nil || (isPod(obj) && obj.GetUID() != "") {
+		return false, nil
+	}
+
+	level := m.levelFor(mutable.Namespace)
+	if level != mutationsv1alpha1.PodSecurityLevelBaseline && level != mutationsv1alpha1.PodSecurityLevelRestricted {
+		return false, nil
+	}
+
+	spec, found, err := unstructured.NestedMap(obj.Object, specPath...)
+	if err != nil || !found {
+		return false, err
+	}
+	osName, _, _ := unstructured.NestedString(spec, "os", "name")
+	linux := osName != string(corev1.Windows)
+
+	mutated := false
+	if linux {
+		changed, err := setDefault(spec, map[string]interface{}{"type": string(corev1.SeccompProfileTypeRuntimeDefault)}, "securityContext", "seccompProfile")
+		if err != nil {
+			return false, err
+		}
+		mutated = mutated || changed
+	}
+
+	if level == mutationsv1alpha1.PodSecurityLevelRestricted {
+		// A pod explicitly running as root would never start with runAsNonRoot.
+		if !runsAsRoot(spec) {
+			changed, err := setDefault(spec, true, "securityContext", "runAsNonRoot")
+			if err != nil {
+				return false, err
+			}
+			mutated = mutated || changed
+		}
+
+		for _, field := range containerFieldsFor(nil) {
+			if !linux {
+				break
+			}
+			containers := namedList(spec, field)
+			m.warn(mutable, dropWarnings(containers))
+			changed, err := restrictContainers(containers)
+			if err != nil {
+				return false, err
+			}
+			if !changed {
+				continue
+			}
+			if err := setNamedList(spec, containers, field); err != nil {
+				return false, err
+			}
+			mutated = true
+		}
+	}
+
+	if !mutated {
+		return false, nil
+	}
+	if err := unstructured.SetNestedMap(obj.Object, spec, specPath...); err != nil {
+		return false, err
+	}
+	podSecurityLog.V(4).Info("Applied pod security defaults", "mutator", m.id, "level", level)
+	return true, nil
+}
+
+// restrictContainers sets the container level fields required by the
+// restricted profile on every container that does not set them.
+func restrictContainers(containers []map[string]interface{}) (bool, error) {
+	mutated := false
+	for _, container := range containers {
+		// privileged containers cannot disable privilege escalation.
+		if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); privileged {
+			continue
+		}
+		changed, err := setDefault(container, false, "securityContext", "allowPrivilegeEscalation")
+		if err != nil {
+			return false, err
+		}
+		mutated = mutated || changed
+		changed, err = setDefault(container, []interface{}{"ALL"}, "securityContext", "capabilities", "drop")
+		if err != nil {
+			return false, err
+		}
+		mutated = mutated || changed
+	}
+	return mutated, nil
+}
+
+// dropWarnings returns a warning for every container that explicitly drops
+// capabilities without dropping ALL, which the restricted profile requires.
+// The list is left as is, as the pod may rely on the capabilities it keeps.
+func dropWarnings(containers []map[string]interface{}) []string {
+	var warnings []string
+	for _, container := range containers {
+		if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); privileged {
+			continue
+		}
+		drop, found, _ := unstructured.NestedStringSlice(container, "securityContext", "capabilities", "drop")
+		if found && !containsString(drop, "ALL") {
+			name, _, _ := unstructured.NestedString(container, "name")
+			warnings = append(warnings, fmt.Sprintf("container %q does not drop ALL capabilities, which the restricted Pod Security Standard requires", name))
+		}
+	}
+	return warnings
+}
+
+// warn reports warnings for the admission response.
+func (m *PodSecurityDefaultMutator) warn(mutable *types.Mutable, warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	if r := report.For(mutable); r != nil {
+		r.AddWarnings(warnings...)
+	} else {
+		podSecurityLog.Info("Pod security warnings", "mutator", m.id, "warnings", warnings)
+	}
+}
+
+// runsAsRoot returns true if a container of the pod spec explicitly runs as
+// root, through its own runAsUser or the one of the pod.
+func runsAsRoot(spec map[string]interface{}) bool {
+	podUID, podFound, _ := unstructured.NestedInt64(spec, "securityContext", "runAsUser")
+	for _, field := range containerFieldsFor(nil) {
+		for _, container := range namedList(spec, field) {
+			uid, found, _ := unstructured.NestedInt64(container, "securityContext", "runAsUser")
+			if !found {
+				uid, found = podUID, podFound
+			}
+			if found && uid == 0 {
+				return true
+			}
+		}
+	}
+	return podFound && podUID == 0
+}
+
+// setDefault sets obj[fields...] to value unless it is already set.
+func setDefault(obj map[string]interface{}, value interface{}, fields ...string) (bool, error) {
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj, fields...); found {
+		return false, nil
+	}
+	return true, unstructured.SetNestedField(obj, value, fields...)
+}
+
+// levelFor returns the Pod Security Standards level enforced for ns.
+func (m *PodSecurityDefaultMutator) levelFor(ns *corev1.Namespace) mutationsv1alpha1.PodSecurityLevel {
+	if ns == nil {
+		return m.defaults.Spec.DefaultLevel
+	}
+	value, ok := ns.GetLabels()[mutationsv1alpha1.PodSecurityEnforceLabel]
+	if !ok {
+		return m.defaults.Spec.DefaultLevel
+	}
+	switch level := mutationsv1alpha1.PodSecurityLevel(value); level {
+	case mutationsv1alpha1.PodSecurityLevelPrivileged, mutationsv1alpha1.PodSecurityLevelBaseline:
+		return level
+	default:
+		// Pod Security Admission enforces restricted for unknown levels.
+		return mutationsv1alpha1.PodSecurityLevelRestricted
+	}
+}
+
+func (m *PodSecurityDefaultMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *PodSecurityDefaultMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *PodSecurityDefaultMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*PodSecurityDefaultMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.defaults.Spec, m.defaults.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *PodSecurityDefaultMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *PodSecurityDefaultMutator) DeepCopy() types.Mutator {
+	res := &PodSecurityDefaultMutator{
+		id:       m.id,
+		defaults: m.defaults.DeepCopy(),
+	}
+	return res
+}
+
+func (m *PodSecurityDefaultMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.defaults.GetGeneration())
+}
+
+// MutatorForPodSecurityDefault returns a mutator built from the given PodSecurityDefault instance.
+func MutatorForPodSecurityDefault(defaults *mutationsv1alpha1.PodSecurityDefault) (*PodSecurityDefaultMutator, error) {
+	podSecurityLog.V(1).Info("Creating mutator", "podsecuritydefault", defaults)
+	if err := core.ValidateName(defaults.Name); err != nil {
+		return nil, err
+	}
+	switch defaults.Spec.DefaultLevel {
+	case "", mutationsv1alpha1.PodSecurityLevelPrivileged, mutationsv1alpha1.PodSecurityLevelBaseline, mutationsv1alpha1.PodSecurityLevelRestricted:
+	default:
+		return nil, fmt.Errorf("invalid defaultLevel %q", defaults.Spec.DefaultLevel)
+	}
+	// This is not always set by the kubernetes API server
+	defaults.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "PodSecurityDefault"})
+	return &PodSecurityDefaultMutator{
+		id:       types.MakeID(defaults),
+		defaults: defaults.DeepCopy(),
+	}, nil
+}
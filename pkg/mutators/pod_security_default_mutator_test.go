@@ -0,0 +1,108 @@
+package mutators
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+func TestPodSecurityDefaultMutate(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultLevel mutationsv1alpha1.PodSecurityLevel
+		// level labels the namespace if not empty.
+		level        string
+		obj          string
+		want         string
+		wantWarnings []string
+	}{{
+		name: "no level",
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+	}, {
+		name:         "baseline",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelBaseline,
+		obj:          `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want:         `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"}},"containers":[{"name":"app"}]}}`,
+	}, {
+		name:         "restricted",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		obj:          `{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init"}],"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"},"runAsNonRoot":true},
+			"initContainers":[{"name":"init","securityContext":{"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}}],
+			"containers":[{"name":"app","securityContext":{"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}}]}}`,
+	}, {
+		name:  "namespace label",
+		level: "restricted",
+		obj:   `{"apiVersion":"apps/v1","kind":"Deployment","spec":{"template":{"spec":{"containers":[{"name":"app"}]}}}}`,
+		want: `{"apiVersion":"apps/v1","kind":"Deployment","spec":{"template":{"spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"},"runAsNonRoot":true},
+			"containers":[{"name":"app","securityContext":{"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]}}}]}}}}`,
+	}, {
+		name:         "privileged namespace",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		level:        "privileged",
+		obj:          `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want:         `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+	}, {
+		name:  "unknown level is restricted",
+		level: "unknown",
+		obj:   `{"apiVersion":"v1","kind":"Pod","spec":{"os":{"name":"windows"},"containers":[{"name":"app"}]}}`,
+		want:  `{"apiVersion":"v1","kind":"Pod","spec":{"os":{"name":"windows"},"securityContext":{"runAsNonRoot":true},"containers":[{"name":"app"}]}}`,
+	}, {
+		name:         "explicit values are kept",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		obj: `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"Localhost"},"runAsNonRoot":false},
+			"containers":[{"name":"app","securityContext":{"allowPrivilegeEscalation":true,"capabilities":{"drop":["ALL"]}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"Localhost"},"runAsNonRoot":false},
+			"containers":[{"name":"app","securityContext":{"allowPrivilegeEscalation":true,"capabilities":{"drop":["ALL"]}}}]}}`,
+	}, {
+		name:         "container running as root",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		obj:          `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"},"runAsUser":1000},"containers":[{"name":"app","securityContext":{"runAsUser":0,"privileged":true}}]}}`,
+		want:         `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"},"runAsUser":1000},"containers":[{"name":"app","securityContext":{"runAsUser":0,"privileged":true}}]}}`,
+	}, {
+		name:         "drop without ALL",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		obj:          `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","securityContext":{"capabilities":{"drop":["NET_RAW"]}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"securityContext":{"seccompProfile":{"type":"RuntimeDefault"},"runAsNonRoot":true},
+			"containers":[{"name":"app","securityContext":{"allowPrivilegeEscalation":false,"capabilities":{"drop":["NET_RAW"]}}}]}}`,
+		// Both passes warn; the webhook deduplicates warnings.
+		wantWarnings: []string{
+			`container "app" does not drop ALL capabilities, which the restricted Pod Security Standard requires`,
+			`container "app" does not drop ALL capabilities, which the restricted Pod Security Standard requires`,
+		},
+	}, {
+		name:         "existing pod",
+		defaultLevel: mutationsv1alpha1.PodSecurityLevelRestricted,
+		obj:          `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+		want:         `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := MutatorForPodSecurityDefault(&mutationsv1alpha1.PodSecurityDefault{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec:       mutationsv1alpha1.PodSecurityDefaultSpec{DefaultLevel: tt.defaultLevel},
+			})
+			if err != nil {
+				t.Fatalf("MutatorForPodSecurityDefault() = %v", err)
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			if tt.level != "" {
+				ns.Labels = map[string]string{mutationsv1alpha1.PodSecurityEnforceLabel: tt.level}
+			}
+			mutable := &types.Mutable{Object: object(t, tt.obj), Namespace: ns}
+			mutated, r := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+			if diff := cmp.Diff(tt.wantWarnings, r.Warnings()); diff != "" {
+				t.Errorf("warnings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package mutators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"kubesphere.io/muato/pkg/report"
+)
+
+// object decodes a JSON object with its kind, with integers as int64 like
+// in admission requests.
+func object(t *testing.T, raw string) *unstructured.Unstructured {
+	t.Helper()
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON([]byte(raw)); err != nil {
+		t.Fatalf("invalid JSON %s: %v", raw, err)
+	}
+	return obj
+}
+
+// mutate mutates mutable with m under a report, as the webhook does, and
+// checks that a second pass of the mutation system changes nothing. It
+// returns the result of the first pass and the report.
+func mutate(t *testing.T, m types.Mutator, mutable *types.Mutable) (bool, *report.Report) {
+	t.Helper()
+	r := report.Begin(context.Background(), mutable)
+	t.Cleanup(func() { report.End(mutable) })
+
+	mutated, err := m.Mutate(mutable)
+	if err != nil {
+		t.Fatalf("Mutate() = %v", err)
+	}
+	want := mutable.Object.DeepCopy()
+	again, err := m.Mutate(mutable)
+	if err != nil {
+		t.Fatalf("second Mutate() = %v", err)
+	}
+	if again {
+		t.Error("second Mutate() = true, want false")
+	}
+	if diff := cmp.Diff(want.Object, mutable.Object.Object); diff != "" {
+		t.Errorf("second Mutate() changed the object (-want +got):\n%s", diff)
+	}
+	return mutated, r
+}
+
+// checkObject compares obj to the JSON object want.
+func checkObject(t *testing.T, want string, obj *unstructured.Unstructured) {
+	t.Helper()
+	if diff := cmp.Diff(object(t, want).Object, obj.Object); diff != "" {
+		t.Errorf("mutated object (-want +got):\n%s", diff)
+	}
+}
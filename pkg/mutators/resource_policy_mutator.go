@@ -0,0 +1,329 @@
+package mutators
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var resourcePolicyLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "resourcepolicy")
+
+// resourceContainerTypes is used when a policy does not limit the container
+// types. Ephemeral containers cannot have resources.
+var resourceContainerTypes = []mutationsv1alpha1.ContainerType{
+	mutationsv1alpha1.ContainerTypeInitContainers,
+	mutationsv1alpha1.ContainerTypeContainers,
+}
+
+// ResourcePolicyMutator is a mutator object built out of a ResourcePolicy instance.
+type ResourcePolicyMutator struct {
+	id     types.ID
+	policy *mutationsv1alpha1.ResourcePolicy
+}
+
+// ResourcePolicyMutator implements mutator.
+var _ types.Mutator = &ResourcePolicyMutator{}
+
+func (m *ResourcePolicyMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if !isPod(mutable.Object) {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.policy.Spec.Match, target)
+}
+
+func (m *ResourcePolicyMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *ResourcePolicyMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	// Container resources of existing pods are immutable.
+	if obj.GetUID() != "" {
+		return false, nil
+	}
+
+	containerTypes := m.policy.Spec.ContainerTypes
+	if len(containerTypes) == 0 {
+		containerTypes = resourceContainerTypes
+	}
+	mutated := false
+	for _, field := range containerFieldsFor(containerTypes) {
+		containers := namedList(obj.Object, "spec", field)
+		changed := false
+		for _, container := range containers {
+			for i := range m.policy.Spec.Rules {
+				c, err := applyResourceRule(container, &m.policy.Spec.Rules[i])
+				if err != nil {
+					return false, fmt.Errorf("%s %q: %w", field, container["name"], err)
+				}
+				changed = changed || c
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := setNamedList(obj.Object, containers, "spec", field); err != nil {
+			return false, err
+		}
+		mutated = true
+	}
+	if mutated {
+		resourcePolicyLog.V(4).Info("Adjusted container resources", "mutator", m.id)
+	}
+	return mutated, nil
+}
+
+func (m *ResourcePolicyMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *ResourcePolicyMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *ResourcePolicyMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*ResourcePolicyMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.policy.Spec, m.policy.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *ResourcePolicyMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *ResourcePolicyMutator) DeepCopy() types.Mutator {
+	res := &ResourcePolicyMutator{
+		id:     m.id,
+		policy: m.policy.DeepCopy(),
+	}
+	return res
+}
+
+func (m *ResourcePolicyMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.policy.GetGeneration())
+}
+
+// MutatorForResourcePolicy returns a mutator built from the given ResourcePolicy instance.
+func MutatorForResourcePolicy(policy *mutationsv1alpha1.ResourcePolicy) (*ResourcePolicyMutator, error) {
+	resourcePolicyLog.V(1).Info("Creating mutator", "resourcepolicy", policy)
+	if err := core.ValidateName(policy.Name); err != nil {
+		return nil, err
+	}
+	for i, t := range policy.Spec.ContainerTypes {
+		if t == mutationsv1alpha1.ContainerTypeEphemeralContainers {
+			return nil, fmt.Errorf("containerTypes[%d]: ephemeral containers cannot have resources", i)
+		}
+	}
+	seen := make(map[corev1.ResourceName]bool)
+	for i := range policy.Spec.Rules {
+		rule := &policy.Spec.Rules[i]
+		if seen[rule.Resource] {
+			return nil, fmt.Errorf("rules[%d]: duplicate rule for %s", i, rule.Resource)
+		}
+		seen[rule.Resource] = true
+		if err := validateResourceRule(rule); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	// This is not always set by the kubernetes API server
+	policy.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "ResourcePolicy"})
+	return &ResourcePolicyMutator{
+		id:     types.MakeID(policy),
+		policy: policy.DeepCopy(),
+	}, nil
+}
+
+func validateResourceRule(rule *mutationsv1alpha1.ResourceRule) error {
+	switch rule.Resource {
+	case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+	default:
+		return fmt.Errorf("unsupported resource %q", rule.Resource)
+	}
+	for name, q := range map[string]*resource.Quantity{
+		"defaultRequest":         rule.DefaultRequest,
+		"defaultLimit":           rule.DefaultLimit,
+		"min":                    rule.Min,
+		"max":                    rule.Max,
+		"minRequestToLimitRatio": rule.MinRequestToLimitRatio,
+		"maxRequestToLimitRatio": rule.MaxRequestToLimitRatio,
+	} {
+		if q != nil && q.Sign() < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if lessThan(rule.DefaultLimit, rule.DefaultRequest) {
+		return fmt.Errorf("defaultRequest must not be greater than defaultLimit")
+	}
+	if lessThan(rule.Max, rule.Min) {
+		return fmt.Errorf("min must not be greater than max")
+	}
+	if lessThan(rule.MaxRequestToLimitRatio, rule.MinRequestToLimitRatio) {
+		return fmt.Errorf("minRequestToLimitRatio must not be greater than maxRequestToLimitRatio")
+	}
+	one := resource.MustParse("1")
+	if lessThan(&one, rule.MinRequestToLimitRatio) || lessThan(&one, rule.MaxRequestToLimitRatio) {
+		return fmt.Errorf("request to limit ratios must not be greater than 1")
+	}
+	return nil
+}
+
+// applyResourceRule adjusts the requests and limits of container according to
+// rule. It returns true if the container was changed.
+func applyResourceRule(container map[string]interface{}, rule *mutationsv1alpha1.ResourceRule) (bool, error) {
+	request, err := containerQuantity(container, "requests", rule.Resource)
+	if err != nil {
+		return false, err
+	}
+	limit, err := containerQuantity(container, "limits", rule.Resource)
+	if err != nil {
+		return false, err
+	}
+	origRequest, origLimit := request, limit
+
+	if limit == nil && rule.DefaultLimit != nil {
+		limit = rule.DefaultLimit
+	}
+	if request == nil && rule.DefaultRequest != nil {
+		request = rule.DefaultRequest
+		if limit != nil && limit.Cmp(*request) < 0 {
+			// Never let a default request exceed an explicit limit.
+			request = limit
+		}
+	}
+
+	limit = clamp(limit, rule.Min, rule.Max)
+	if request == nil && rule.MaxRequestToLimitRatio != nil {
+		// The API server would default the request to the limit, which
+		// the ratio does not allow.
+		request = scaleQuantity(limit, rule.MaxRequestToLimitRatio, rule.Resource)
+	}
+	request = clamp(request, rule.Min, rule.Max)
+
+	if limit != nil && request != nil {
+		if lowest := scaleQuantity(limit, rule.MinRequestToLimitRatio, rule.Resource); lessThan(request, lowest) {
+			request = lowest
+		}
+		if highest := scaleQuantity(limit, rule.MaxRequestToLimitRatio, rule.Resource); lessThan(highest, request) {
+			request = highest
+		}
+		if lessThan(limit, request) {
+			request = limit
+		}
+	}
+
+	changed := false
+	if !equalQuantity(origRequest, request) {
+		if err := unstructured.SetNestedField(container, request.String(), "resources", "requests", string(rule.Resource)); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if !equalQuantity(origLimit, limit) {
+		if err := unstructured.SetNestedField(container, limit.String(), "resources", "limits", string(rule.Resource)); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// containerQuantity returns the quantity at resources.<kind>.<name>, or nil if unset.
+func containerQuantity(container map[string]interface{}, kind string, name corev1.ResourceName) (*resource.Quantity, error) {
+	value, found, err := unstructured.NestedFieldNoCopy(container, "resources", kind, string(name))
+	if err != nil || !found || value == nil {
+		return nil, err
+	}
+	var q resource.Quantity
+	switch v := value.(type) {
+	case string:
+		if q, err = resource.ParseQuantity(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %s %q: %w", name, kind, v, err)
+		}
+	case int64:
+		q = *resource.NewQuantity(v, resource.DecimalSI)
+	case float64:
+		if q, err = resource.ParseQuantity(fmt.Sprint(v)); err != nil {
+			return nil, fmt.Errorf("invalid %s %s %v: %w", name, kind, v, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid %s %s %v", name, kind, v)
+	}
+	return &q, nil
+}
+
+// scaleQuantity returns q * ratio rounded up to the smallest unit that is
+// meaningful for the resource: millicores for cpu and bytes otherwise.
+func scaleQuantity(q, ratio *resource.Quantity, name corev1.ResourceName) *resource.Quantity {
+	if q == nil || ratio == nil {
+		return nil
+	}
+	scale := resource.Scale(0)
+	if name == corev1.ResourceCPU {
+		scale = resource.Milli
+	}
+	// ratio is at most 1, so its milli value is exact enough for a ratio and
+	// the product is computed with arbitrary precision to avoid overflow.
+	product := new(big.Int).Mul(big.NewInt(q.ScaledValue(scale)), big.NewInt(ratio.MilliValue()))
+	value, rem := new(big.Int).QuoRem(product, big.NewInt(1000), new(big.Int))
+	if rem.Sign() > 0 {
+		value.Add(value, big.NewInt(1))
+	}
+	if name == corev1.ResourceCPU {
+		return resource.NewMilliQuantity(value.Int64(), resource.DecimalSI)
+	}
+	return resource.NewQuantity(value.Int64(), q.Format)
+}
+
+// clamp bounds q to [lowest, highest]. A nil bound is ignored.
+func clamp(q, lowest, highest *resource.Quantity) *resource.Quantity {
+	if q == nil {
+		return nil
+	}
+	if lessThan(q, lowest) {
+		return lowest
+	}
+	if lessThan(highest, q) {
+		return highest
+	}
+	return q
+}
+
+// lessThan returns true if both quantities are set and a < b.
+func lessThan(a, b *resource.Quantity) bool {
+	return a != nil && b != nil && a.Cmp(*b) < 0
+}
+
+func equalQuantity(a, b *resource.Quantity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(*b) == 0
+}
@@ -0,0 +1,187 @@
+package mutators
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+func quantity(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestScaleQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        *resource.Quantity
+		ratio    *resource.Quantity
+		resource corev1.ResourceName
+		want     string
+	}{{
+		name:     "cpu",
+		q:        quantity("1"),
+		ratio:    quantity("0.5"),
+		resource: corev1.ResourceCPU,
+		want:     "500m",
+	}, {
+		name:     "cpu is rounded up to millicores",
+		q:        quantity("3m"),
+		ratio:    quantity("0.5"),
+		resource: corev1.ResourceCPU,
+		want:     "2m",
+	}, {
+		name:     "memory keeps its format",
+		q:        quantity("1Gi"),
+		ratio:    quantity("0.5"),
+		resource: corev1.ResourceMemory,
+		want:     "512Mi",
+	}, {
+		name:     "memory is rounded up to bytes",
+		q:        quantity("3"),
+		ratio:    quantity("0.5"),
+		resource: corev1.ResourceMemory,
+		want:     "2",
+	}, {
+		name:     "large values do not overflow",
+		q:        quantity("4Ei"),
+		ratio:    quantity("0.25"),
+		resource: corev1.ResourceEphemeralStorage,
+		want:     "1Ei",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaleQuantity(tt.q, tt.ratio, tt.resource); got.String() != tt.want {
+				t.Errorf("scaleQuantity() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+	if got := scaleQuantity(quantity("1"), nil, corev1.ResourceCPU); got != nil {
+		t.Errorf("scaleQuantity() without ratio = %v, want nil", got)
+	}
+}
+
+func TestResourcePolicyMutate(t *testing.T) {
+	tests := []struct {
+		name           string
+		containerTypes []mutationsv1alpha1.ContainerType
+		rule           mutationsv1alpha1.ResourceRule
+		obj            string
+		want           string
+	}{{
+		name: "defaults",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, DefaultRequest: quantity("100m"), DefaultLimit: quantity("1")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"100m"},"limits":{"cpu":"1"}}}]}}`,
+	}, {
+		name: "default request does not exceed the limit",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, DefaultRequest: quantity("100m")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":"50m"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"50m"},"limits":{"cpu":"50m"}}}]}}`,
+	}, {
+		name: "min and max",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceMemory, Min: quantity("64Mi"), Max: quantity("1Gi")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"32Mi"},"limits":{"memory":"2Gi"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"64Mi"},"limits":{"memory":"1Gi"}}}]}}`,
+	}, {
+		name: "request derived from the limit",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceMemory, MaxRequestToLimitRatio: quantity("0.5")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"limits":{"memory":"1Gi"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"512Mi"},"limits":{"memory":"1Gi"}}}]}}`,
+	}, {
+		name: "request derived from the clamped limit",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, Max: quantity("2"), MaxRequestToLimitRatio: quantity("0.5")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":"4"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"1"},"limits":{"cpu":"2"}}}]}}`,
+	}, {
+		name: "min ratio raises the request",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceMemory, MinRequestToLimitRatio: quantity("0.25")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"100Mi"},"limits":{"memory":"1Gi"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"256Mi"},"limits":{"memory":"1Gi"}}}]}}`,
+	}, {
+		name: "compliant container",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceMemory, Min: quantity("64Mi"), MaxRequestToLimitRatio: quantity("0.5")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"0.25Gi"},"limits":{"memory":"1Gi"}}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"app","resources":{"requests":{"memory":"0.25Gi"},"limits":{"memory":"1Gi"}}}]}}`,
+	}, {
+		name: "init containers but not ephemeral containers",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, DefaultRequest: quantity("100m")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init"}],"containers":[{"name":"app"}],"ephemeralContainers":[{"name":"debug"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init","resources":{"requests":{"cpu":"100m"}}}],
+			"containers":[{"name":"app","resources":{"requests":{"cpu":"100m"}}}],"ephemeralContainers":[{"name":"debug"}]}}`,
+	}, {
+		name:           "container types",
+		containerTypes: []mutationsv1alpha1.ContainerType{mutationsv1alpha1.ContainerTypeInitContainers},
+		rule:           mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, DefaultRequest: quantity("100m")},
+		obj:            `{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init"}],"containers":[{"name":"app"}]}}`,
+		want:           `{"apiVersion":"v1","kind":"Pod","spec":{"initContainers":[{"name":"init","resources":{"requests":{"cpu":"100m"}}}],"containers":[{"name":"app"}]}}`,
+	}, {
+		name: "existing pod",
+		rule: mutationsv1alpha1.ResourceRule{Resource: corev1.ResourceCPU, DefaultRequest: quantity("100m")},
+		obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := MutatorForResourcePolicy(&mutationsv1alpha1.ResourcePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+				Spec: mutationsv1alpha1.ResourcePolicySpec{
+					ContainerTypes: tt.containerTypes,
+					Rules:          []mutationsv1alpha1.ResourceRule{tt.rule},
+				},
+			})
+			if err != nil {
+				t.Fatalf("MutatorForResourcePolicy() = %v", err)
+			}
+			mutable := &types.Mutable{Object: object(t, tt.obj)}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+		})
+	}
+}
+
+func TestMutatorForResourcePolicyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec mutationsv1alpha1.ResourcePolicySpec
+	}{{
+		name: "ephemeral containers",
+		spec: mutationsv1alpha1.ResourcePolicySpec{
+			ContainerTypes: []mutationsv1alpha1.ContainerType{mutationsv1alpha1.ContainerTypeEphemeralContainers},
+		},
+	}, {
+		name: "duplicate rules",
+		spec: mutationsv1alpha1.ResourcePolicySpec{Rules: []mutationsv1alpha1.ResourceRule{
+			{Resource: corev1.ResourceCPU},
+			{Resource: corev1.ResourceCPU},
+		}},
+	}, {
+		name: "min greater than max",
+		spec: mutationsv1alpha1.ResourcePolicySpec{Rules: []mutationsv1alpha1.ResourceRule{
+			{Resource: corev1.ResourceCPU, Min: quantity("2"), Max: quantity("1")},
+		}},
+	}, {
+		name: "ratio greater than 1",
+		spec: mutationsv1alpha1.ResourcePolicySpec{Rules: []mutationsv1alpha1.ResourceRule{
+			{Resource: corev1.ResourceCPU, MaxRequestToLimitRatio: quantity("1.5")},
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MutatorForResourcePolicy(&mutationsv1alpha1.ResourcePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+				Spec:       tt.spec,
+			})
+			if err == nil {
+				t.Error("MutatorForResourcePolicy() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package mutators
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/mutators/core"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/path/parser"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/schema"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var sidecarLog = logf.Log.WithName("mutation").WithValues(logging.Process, "mutation", logging.Mutator, "sidecarinjection")
+
+// SidecarInjectionMutator is a mutator object built out of a SidecarInjection instance.
+type SidecarInjectionMutator struct {
+	id        types.ID
+	injection *mutationsv1alpha1.SidecarInjection
+	selector  labels.Selector
+
+	containers     []map[string]interface{}
+	initContainers []map[string]interface{}
+	volumes        []map[string]interface{}
+}
+
+// SidecarInjectionMutator implements mutator.
+var _ types.Mutator = &SidecarInjectionMutator{}
+
+func (m *SidecarInjectionMutator) Matches(mutable *types.Mutable) (bool, error) {
+	if !isPod(mutable.Object) {
+		return false, nil
+	}
+	if !m.selector.Matches(labels.Set(mutable.Object.GetLabels())) {
+		return false, nil
+	}
+	target := &match.Matchable{
+		Object:    mutable.Object,
+		Namespace: mutable.Namespace,
+		Source:    mutable.Source,
+	}
+	return match.Matches(&m.injection.Spec.Match, target)
+}
+
+func (m *SidecarInjectionMutator) TerminalType() parser.NodeType {
+	return schema.Unknown
+}
+
+func (m *SidecarInjectionMutator) Mutate(mutable *types.Mutable) (bool, error) {
+	obj := mutable.Object
+	if strings.EqualFold(obj.GetAnnotations()[mutationsv1alpha1.SidecarInjectAnnotation], "false") {
+		return false, nil
+	}
+	// Pods that already exist were either injected on create or opted out by
+	// being created before this SidecarInjection; their containers are immutable.
+	if obj.GetUID() != "" {
+		return false, nil
+	}
+
+	values := podTemplateValues(obj)
+	containers := namedList(obj.Object, "spec", containersField)
+	initContainers := namedList(obj.Object, "spec", initContainersField)
+	volumes := namedList(obj.Object, "spec", volumesField)
+	existing := names(containers, initContainers)
+
+	var injectedInit []map[string]interface{}
+	for _, c := range m.initContainers {
+		if existing[c["name"].(string)] {
+			continue
+		}
+		rendered, err := renderTemplate(c, values)
+		if err != nil {
+			return false, fmt.Errorf("rendering init container %q: %w", c["name"], err)
+		}
+		injectedInit = append(injectedInit, rendered)
+	}
+
+	var injected []map[string]interface{}
+	for _, c := range m.containers {
+		if existing[c["name"].(string)] {
+			continue
+		}
+		rendered, err := renderTemplate(c, values)
+		if err != nil {
+			return false, fmt.Errorf("rendering container %q: %w", c["name"], err)
+		}
+		injected = append(injected, rendered)
+	}
+
+	existingVolumes := names(volumes)
+	var injectedVolumes []map[string]interface{}
+	for _, v := range m.volumes {
+		if existingVolumes[v["name"].(string)] {
+			continue
+		}
+		rendered, err := renderTemplate(v, values)
+		if err != nil {
+			return false, fmt.Errorf("rendering volume %q: %w", v["name"], err)
+		}
+		injectedVolumes = append(injectedVolumes, rendered)
+	}
+
+	if len(injectedInit) == 0 && len(injected) == 0 && len(injectedVolumes) == 0 {
+		return false, nil
+	}
+
+	if len(injectedInit) > 0 {
+		if err := setNamedList(obj.Object, append(injectedInit, initContainers...), "spec", initContainersField); err != nil {
+			return false, err
+		}
+	}
+	if len(injected) > 0 {
+		if err := setNamedList(obj.Object, append(containers, injected...), "spec", containersField); err != nil {
+			return false, err
+		}
+	}
+	if len(injectedVolumes) > 0 {
+		if err := setNamedList(obj.Object, append(volumes, injectedVolumes...), "spec", volumesField); err != nil {
+			return false, err
+		}
+	}
+	sidecarLog.V(4).Info("Injected sidecars", "mutator", m.id, "initContainers", len(injectedInit), "containers", len(injected), "volumes", len(injectedVolumes))
+	return true, nil
+}
+
+func (m *SidecarInjectionMutator) MustTerminate() bool {
+	return true
+}
+
+func (m *SidecarInjectionMutator) ID() types.ID {
+	return m.id
+}
+
+func (m *SidecarInjectionMutator) HasDiff(mutator types.Mutator) bool {
+	toCheck, ok := mutator.(*SidecarInjectionMutator)
+	if !ok { // different types, different
+		return true
+	}
+	if !cmp.Equal(toCheck.id, m.id) {
+		return true
+	}
+	// any difference in spec may be enough
+	if !cmp.Equal(toCheck.injection.Spec, m.injection.Spec) {
+		return true
+	}
+
+	return false
+}
+
+func (m *SidecarInjectionMutator) Path() parser.Path {
+	return parser.Path{}
+}
+
+func (m *SidecarInjectionMutator) DeepCopy() types.Mutator {
+	// the rendered templates are never modified after construction, so they
+	// are shared between copies.
+	res := &SidecarInjectionMutator{
+		id:             m.id,
+		injection:      m.injection.DeepCopy(),
+		selector:       m.selector,
+		containers:     m.containers,
+		initContainers: m.initContainers,
+		volumes:        m.volumes,
+	}
+	return res
+}
+
+func (m *SidecarInjectionMutator) String() string {
+	return fmt.Sprintf("%s/%s/%s:%d", m.id.Kind, m.id.Namespace, m.id.Name, m.injection.GetGeneration())
+}
+
+// MutatorForSidecarInjection returns a mutator built from the given SidecarInjection instance.
+func MutatorForSidecarInjection(injection *mutationsv1alpha1.SidecarInjection) (*SidecarInjectionMutator, error) {
+	sidecarLog.V(1).Info("Creating mutator", "sidecarinjection", injection)
+	if err := core.ValidateName(injection.Name); err != nil {
+		return nil, err
+	}
+
+	selector := labels.Everything()
+	if injection.Spec.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(injection.Spec.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+
+	containers, err := templatesFor("containers", injection.Spec.Containers)
+	if err != nil {
+		return nil, err
+	}
+	initContainers, err := templatesFor("initContainers", injection.Spec.InitContainers)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := templatesFor("volumes", injection.Spec.Volumes)
+	if err != nil {
+		return nil, err
+	}
+	seen := names(containers, initContainers)
+	if len(seen) != len(containers)+len(initContainers) {
+		return nil, fmt.Errorf("container names must be unique across containers and initContainers")
+	}
+
+	// This is not always set by the kubernetes API server
+	injection.SetGroupVersionKind(runtimeschema.GroupVersionKind{Group: mutationsv1alpha1.GroupVersion.Group, Kind: "SidecarInjection"})
+	return &SidecarInjectionMutator{
+		id:             types.MakeID(injection),
+		injection:      injection.DeepCopy(),
+		selector:       selector,
+		containers:     containers,
+		initContainers: initContainers,
+		volumes:        volumes,
+	}, nil
+}
+
+// templatesFor converts the given named items into unstructured templates and
+// verifies that every item has a name and that all embedded templates parse.
+func templatesFor[T any](field string, items []T) ([]map[string]interface{}, error) {
+	result, err := toUnstructuredList(items)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	for i, item := range result {
+		if name, _ := item["name"].(string); name == "" {
+			return nil, fmt.Errorf("%s[%d]: name is required", field, i)
+		}
+		if _, err := renderTemplate(item, podValues{}); err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", field, i, err)
+		}
+	}
+	return result, nil
+}
+
+// podValues are the pod metadata fields available to templates.
+type podValues struct {
+	Name         string
+	GenerateName string
+	Namespace    string
+	Labels       map[string]string
+	Annotations  map[string]string
+}
+
+func podTemplateValues(obj *unstructured.Unstructured) podValues {
+	return podValues{
+		Name:         obj.GetName(),
+		GenerateName: obj.GetGenerateName(),
+		Namespace:    obj.GetNamespace(),
+		Labels:       obj.GetLabels(),
+		Annotations:  obj.GetAnnotations(),
+	}
+}
@@ -0,0 +1,99 @@
+package mutators
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+func TestSidecarInjectionMutate(t *testing.T) {
+	spec := mutationsv1alpha1.SidecarInjectionSpec{
+		InitContainers: []corev1.Container{{Name: "init-proxy", Image: "proxy"}},
+		Containers: []corev1.Container{{
+			Name:  "proxy",
+			Image: "proxy",
+			Args:  []string{"--namespace={{ .Namespace }}", `--app={{ index .Labels "app" }}`},
+		}},
+		Volumes: []corev1.Volume{{
+			Name:         "proxy-config",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}},
+	}
+	tests := []struct {
+		name string
+		obj  string
+		want string
+	}{{
+		name: "inject",
+		obj: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns","labels":{"app":"web"}},
+			"spec":{"initContainers":[{"name":"init"}],"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns","labels":{"app":"web"}},
+			"spec":{"initContainers":[{"name":"init-proxy","image":"proxy","resources":{}},{"name":"init"}],
+				"containers":[{"name":"app"},{"name":"proxy","image":"proxy","args":["--namespace=ns","--app=web"],"resources":{}}],
+				"volumes":[{"name":"proxy-config","emptyDir":{}}]}}`,
+	}, {
+		name: "existing names are kept",
+		obj: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns"},
+			"spec":{"initContainers":[{"name":"proxy"}],"containers":[{"name":"init-proxy"}],"volumes":[{"name":"proxy-config","configMap":{"name":"config"}}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"ns"},
+			"spec":{"initContainers":[{"name":"proxy"}],"containers":[{"name":"init-proxy"}],"volumes":[{"name":"proxy-config","configMap":{"name":"config"}}]}}`,
+	}, {
+		name: "opted out",
+		obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","annotations":{"sidecar.mutato.kubesphere.io/inject":"False"}},"spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","annotations":{"sidecar.mutato.kubesphere.io/inject":"False"}},"spec":{"containers":[{"name":"app"}]}}`,
+	}, {
+		name: "existing pod",
+		obj:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+		want: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","uid":"uid"},"spec":{"containers":[{"name":"app"}]}}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := MutatorForSidecarInjection(&mutationsv1alpha1.SidecarInjection{
+				ObjectMeta: metav1.ObjectMeta{Name: "proxy"},
+				Spec:       *spec.DeepCopy(),
+			})
+			if err != nil {
+				t.Fatalf("MutatorForSidecarInjection() = %v", err)
+			}
+			mutable := &types.Mutable{Object: object(t, tt.obj)}
+			mutated, _ := mutate(t, m, mutable)
+			if wantMutated := tt.obj != tt.want; mutated != wantMutated {
+				t.Errorf("Mutate() = %t, want %t", mutated, wantMutated)
+			}
+			checkObject(t, tt.want, mutable.Object)
+		})
+	}
+}
+
+func TestMutatorForSidecarInjectionInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec mutationsv1alpha1.SidecarInjectionSpec
+	}{{
+		name: "duplicate names",
+		spec: mutationsv1alpha1.SidecarInjectionSpec{
+			InitContainers: []corev1.Container{{Name: "proxy"}},
+			Containers:     []corev1.Container{{Name: "proxy"}},
+		},
+	}, {
+		name: "missing name",
+		spec: mutationsv1alpha1.SidecarInjectionSpec{Volumes: []corev1.Volume{{}}},
+	}, {
+		name: "invalid template",
+		spec: mutationsv1alpha1.SidecarInjectionSpec{Containers: []corev1.Container{{Name: "proxy", Image: "{{ .Namespace"}}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MutatorForSidecarInjection(&mutationsv1alpha1.SidecarInjection{
+				ObjectMeta: metav1.ObjectMeta{Name: "proxy"},
+				Spec:       tt.spec,
+			})
+			if err == nil {
+				t.Error("MutatorForSidecarInjection() succeeded, want error")
+			}
+		})
+	}
+}
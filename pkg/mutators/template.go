@@ -0,0 +1,62 @@
+package mutators
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// renderTemplate returns a copy of item with every string that contains a
+// template action executed against values.
+func renderTemplate(item map[string]interface{}, values interface{}) (map[string]interface{}, error) {
+	rendered, err := renderValue(runtime.DeepCopyJSONValue(item), values, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+// renderValue executes the templates in value in place. If empty is not nil,
+// it is set when any template renders to an empty string.
+func renderValue(value interface{}, values interface{}, empty *bool) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			r, err := renderValue(elem, values, empty)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = r
+		}
+		return v, nil
+	case []interface{}:
+		for i, elem := range v {
+			r, err := renderValue(elem, values, empty)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = r
+		}
+		return v, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New("").Option("missingkey=zero").Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, values); err != nil {
+			return nil, err
+		}
+		if empty != nil && buf.Len() == 0 {
+			*empty = true
+		}
+		return buf.String(), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,91 @@
+package mutators
+
+import (
+	"context"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubesphere.io/muato/pkg/report"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxOwnerDepth bounds the controller chain followed to find a workload.
+// Built-in workloads are at most two levels above a pod (CronJob -> Job -> Pod).
+const maxOwnerDepth = 4
+
+// cachedOwnerKinds are the owners read through the cache. Other owners are
+// read from the API server, as caching them would need permission to watch
+// every kind of controller.
+var cachedOwnerKinds = map[schema.GroupKind]bool{
+	{Group: "apps", Kind: "Deployment"}:  true,
+	{Group: "apps", Kind: "ReplicaSet"}:  true,
+	{Group: "apps", Kind: "StatefulSet"}: true,
+	{Group: "apps", Kind: "DaemonSet"}:   true,
+	{Group: "batch", Kind: "Job"}:        true,
+	{Group: "batch", Kind: "CronJob"}:    true,
+}
+
+// WorkloadGetter looks up the workload owning an object.
+type WorkloadGetter interface {
+	// GetWorkload returns the top-most controller of obj, following
+	// controller owner references, or nil if obj has no controller.
+	// Owners that no longer exist end the chain.
+	GetWorkload(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+}
+
+type workloadGetter struct {
+	cached client.Reader
+	reader client.Reader
+}
+
+// NewWorkloadGetter returns a WorkloadGetter reading built-in owners with
+// cached and other owners with reader.
+func NewWorkloadGetter(cached, reader client.Reader) WorkloadGetter {
+	return &workloadGetter{cached: cached, reader: reader}
+}
+
+func (g *workloadGetter) GetWorkload(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	var workload *unstructured.Unstructured
+	current := obj
+	for i := 0; i < maxOwnerDepth; i++ {
+		ref := metav1.GetControllerOfNoCopy(current)
+		if ref == nil {
+			break
+		}
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		reader := g.reader
+		if cachedOwnerKinds[gvk.GroupKind()] {
+			reader = g.cached
+		}
+		owner := &unstructured.Unstructured{}
+		owner.SetGroupVersionKind(gvk)
+		err := reader.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: ref.Name}, owner)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		workload, current = owner, owner
+	}
+	return workload, nil
+}
+
+// getWorkload returns the workload of the object of mutable with getter,
+// memoized for the request it is mutated for.
+func getWorkload(getter WorkloadGetter, mutable *types.Mutable) (*unstructured.Unstructured, error) {
+	ref := metav1.GetControllerOfNoCopy(mutable.Object)
+	if ref == nil {
+		return nil, nil
+	}
+	r := report.For(mutable)
+	value, err := r.Memo("workload/"+string(ref.UID), func() (interface{}, error) {
+		return getter.GetWorkload(r.Context(), mutable.Object)
+	})
+	workload, _ := value.(*unstructured.Unstructured)
+	return workload, err
+}
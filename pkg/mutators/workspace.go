@@ -0,0 +1,59 @@
+package mutators
+
+import (
+	"context"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"kubesphere.io/muato/pkg/report"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workspaceGVK is the kind of KubeSphere workspaces on member clusters.
+var workspaceGVK = schema.GroupVersionKind{Group: "tenant.kubesphere.io", Version: "v1beta1", Kind: "Workspace"}
+
+// WorkspaceGetter looks up the KubeSphere workspace a namespace belongs to.
+type WorkspaceGetter interface {
+	// GetWorkspace returns the named workspace, or nil if it does not exist
+	// or KubeSphere is not installed.
+	GetWorkspace(ctx context.Context, name string) (*unstructured.Unstructured, error)
+}
+
+type workspaceGetter struct {
+	reader client.Reader
+}
+
+// NewWorkspaceGetter returns a WorkspaceGetter reading workspaces with
+// reader, which should be cached as workspaces are read on every admission
+// request.
+func NewWorkspaceGetter(reader client.Reader) WorkspaceGetter {
+	return &workspaceGetter{reader: reader}
+}
+
+func (g *workspaceGetter) GetWorkspace(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+	workspace := &unstructured.Unstructured{}
+	workspace.SetGroupVersionKind(workspaceGVK)
+	err := g.reader.Get(ctx, client.ObjectKey{Name: name}, workspace)
+	switch {
+	case err == nil:
+		return workspace, nil
+	case apierrors.IsNotFound(err), meta.IsNoMatchError(err):
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// getWorkspace returns the named workspace with getter, memoized for the
+// request mutable is mutated for.
+func getWorkspace(getter WorkspaceGetter, mutable *types.Mutable, name string) (*unstructured.Unstructured, error) {
+	r := report.For(mutable)
+	value, err := r.Memo("workspace/"+name, func() (interface{}, error) {
+		return getter.GetWorkspace(r.Context(), name)
+	})
+	workspace, _ := value.(*unstructured.Unstructured)
+	return workspace, err
+}
@@ -0,0 +1,188 @@
+// Package readiness tracks whether the mutators existing at startup have
+// been ingested into the mutation system, so that the webhook does not
+// admit objects unmutated while the controllers catch up.
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("readiness").WithValues(logging.Process, "readiness")
+
+// listRetryInterval is how long to wait before listing a kind again after
+// the list failed.
+const listRetryInterval = 5 * time.Second
+
+type key struct {
+	kind string
+	name types.NamespacedName
+}
+
+// Tracker is ready once every mutator object that existed when it started
+// has been reconciled at the generation it had then, or a later one. A
+// reconciled object counts even if its mutator failed to be ingested: the
+// failure is reported on the object and would never resolve by waiting.
+type Tracker struct {
+	reader client.Reader
+
+	mu    sync.Mutex
+	kinds []schema.GroupVersionKind
+	// observed are the generations reconciled so far, -1 for deleted
+	// objects.
+	observed map[key]int64
+	// expected are the generations still to be reconciled.
+	expected map[key]int64
+	listed   bool
+	ready    bool
+}
+
+// Every replica serves the webhook and must track its own mutation system.
+var _ manager.LeaderElectionRunnable = &Tracker{}
+
+// NewTracker returns a Tracker listing mutator objects with reader, which
+// should not be cached so that the list is current.
+func NewTracker(reader client.Reader) *Tracker {
+	return &Tracker{
+		reader:   reader,
+		observed: map[key]int64{},
+		expected: map[key]int64{},
+	}
+}
+
+// Expect makes the Tracker wait for the objects of gvk. It must be called
+// before the Tracker is started.
+func (t *Tracker) Expect(gvk schema.GroupVersionKind) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.kinds = append(t.kinds, gvk)
+}
+
+// Observe records that the object of kind named name was reconciled at
+// generation, or deleted. It accepts a nil receiver.
+func (t *Tracker) Observe(kind string, name types.NamespacedName, generation int64, deleted bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ready {
+		return
+	}
+	k := key{kind: kind, name: name}
+	if deleted {
+		generation = -1
+	}
+	t.observed[k] = generation
+	if expected, ok := t.expected[k]; ok && (deleted || generation >= expected) {
+		delete(t.expected, k)
+	}
+	t.update()
+}
+
+// Ready returns true once the mutators existing at startup are ingested. It
+// accepts a nil receiver, which is always ready.
+func (t *Tracker) Ready() bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ready
+}
+
+// Check is a healthz.Checker failing until the Tracker is ready.
+func (t *Tracker) Check(_ *http.Request) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.ready:
+		return nil
+	case !t.listed:
+		return fmt.Errorf("mutators are not listed yet")
+	default:
+		return fmt.Errorf("%d mutators are not ingested yet", len(t.expected))
+	}
+}
+
+// Start lists the objects of the expected kinds, retrying until it
+// succeeds or ctx is done.
+func (t *Tracker) Start(ctx context.Context) error {
+	t.mu.Lock()
+	kinds := t.kinds
+	t.mu.Unlock()
+
+	expected := map[key]int64{}
+	for _, gvk := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := wait.PollUntilContextCancel(ctx, listRetryInterval, true, func(ctx context.Context) (bool, error) {
+			if err := t.reader.List(ctx, list); err != nil {
+				log.Error(err, "Failed to list mutators", "kind", gvk.Kind)
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			// ctx is done.
+			return nil
+		}
+		err = meta.EachListItem(list, func(item runtime.Object) error {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return err
+			}
+			name := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			expected[key{kind: gvk.Kind, name: name}] = obj.GetGeneration()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, generation := range expected {
+		if observed, ok := t.observed[k]; !ok || (observed != -1 && observed < generation) {
+			t.expected[k] = generation
+		}
+	}
+	t.listed = true
+	log.Info("Waiting for mutators to be ingested", "mutators", len(t.expected))
+	t.update()
+	return nil
+}
+
+// update marks the Tracker ready once nothing is expected anymore. t.mu
+// must be held.
+func (t *Tracker) update() {
+	if !t.listed || len(t.expected) > 0 {
+		return
+	}
+	t.ready = true
+	// Observations are only needed until the Tracker is ready.
+	t.observed, t.expected = nil, nil
+	log.Info("All mutators are ingested")
+}
+
+func (t *Tracker) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,192 @@
+// Package redact masks sensitive values of objects before they are logged,
+// exported or passed to rules: the data of Secrets, the values of
+// environment variables with sensitive names, and the fields an object
+// lists in its FieldsAnnotation.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+)
+
+const (
+	// Mask replaces redacted values.
+	Mask = "<redacted>"
+
+	// FieldsAnnotation lists further fields of an object to redact, as
+	// comma separated dotted paths. A "*" segment matches any key or list
+	// index, e.g. "spec.containers.*.args".
+	FieldsAnnotation = "mutato.kubesphere.io/redact-fields"
+)
+
+// secretFields are the fields of a Secret holding its data.
+var secretFields = []string{"data", "stringData"}
+
+// Redactor masks sensitive values. A nil Redactor masks Secret data and
+// annotated fields only.
+type Redactor struct {
+	envNames *regexp.Regexp
+}
+
+// New returns a Redactor masking the values of environment variables whose
+// name matches the envNames regular expression. An empty envNames masks no
+// environment variables.
+func New(envNames string) (*Redactor, error) {
+	r := &Redactor{}
+	if envNames != "" {
+		re, err := regexp.Compile(envNames)
+		if err != nil {
+			return nil, err
+		}
+		r.envNames = re
+	}
+	return r, nil
+}
+
+// JSON returns the JSON object raw with sensitive values masked. Anything
+// that is not a JSON object is masked entirely.
+func (r *Redactor) JSON(raw []byte) []byte {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return []byte(strconv.Quote(Mask))
+	}
+	r.redact(obj)
+	// The mask is kept readable instead of escaping its angle brackets.
+	var redacted bytes.Buffer
+	encoder := json.NewEncoder(&redacted)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(obj); err != nil {
+		return []byte(strconv.Quote(Mask))
+	}
+	return bytes.TrimSuffix(redacted.Bytes(), []byte("\n"))
+}
+
+// Patch returns the JSON patch from the input to the output object, both
+// JSON, with sensitive values masked. Changes of masked values are not part
+// of the patch.
+func (r *Redactor) Patch(input, output []byte) ([]jsonpatch.Operation, error) {
+	return jsonpatch.CreatePatch(r.JSON(input), r.JSON(output))
+}
+
+// redact masks the sensitive values of obj in place.
+func (r *Redactor) redact(obj map[string]interface{}) {
+	if IsSecret(obj) {
+		for _, field := range secretFields {
+			if data, ok := obj[field].(map[string]interface{}); ok {
+				for key := range data {
+					data[key] = Mask
+				}
+			}
+		}
+	}
+	if r != nil && r.envNames != nil {
+		r.redactEnv(obj)
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if fields, ok := annotations[FieldsAnnotation].(string); ok {
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				maskPath(obj, strings.Split(field, "."))
+			}
+		}
+	}
+}
+
+// redactEnv masks the values of sensitive environment variables anywhere
+// in value, e.g. in pods and in the pod templates of workloads.
+func (r *Redactor) redactEnv(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if env, ok := item.([]interface{}); ok && key == "env" {
+				for _, variable := range env {
+					variable, ok := variable.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					name, _ := variable["name"].(string)
+					if _, ok := variable["value"]; ok && r.envNames.MatchString(name) {
+						variable["value"] = Mask
+					}
+				}
+				continue
+			}
+			r.redactEnv(item)
+		}
+	case []interface{}:
+		for _, item := range value {
+			r.redactEnv(item)
+		}
+	}
+}
+
+// maskPath masks the values at path below value and returns value.
+func maskPath(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return Mask
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if path[0] == "*" || path[0] == key {
+				value[key] = maskPath(item, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				value[i] = maskPath(item, path[1:])
+			}
+		}
+	}
+	return value
+}
+
+// IsSecret returns true if obj is a core Secret.
+func IsSecret(obj map[string]interface{}) bool {
+	return obj["apiVersion"] == "v1" && obj["kind"] == "Secret"
+}
+
+// MaskSecretData returns a shallow copy of the Secret obj whose data values
+// are masked, to be passed to rules not allowed to read them.
+func MaskSecretData(obj map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		masked[key] = value
+	}
+	for _, field := range secretFields {
+		data, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		maskedData := make(map[string]interface{}, len(data))
+		for key := range data {
+			maskedData[key] = Mask
+		}
+		masked[field] = maskedData
+	}
+	return masked
+}
+
+// RestoreSecretData replaces the values of output a rule left masked with
+// the values of the original Secret.
+func RestoreSecretData(output, original map[string]interface{}) {
+	for _, field := range secretFields {
+		data, ok := output[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		originalData, _ := original[field].(map[string]interface{})
+		for key, value := range data {
+			if originalValue, ok := originalData[key]; ok && value == Mask {
+				data[key] = originalValue
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gomodules.xyz/jsonpatch/v2"
+)
+
+const envNames = "(?i)password|token"
+
+func TestRedactorJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		envNames string
+		nil      bool
+		in       string
+		want     string
+	}{{
+		name: "secret data",
+		in:   `{"apiVersion":"v1","kind":"Secret","data":{"a":"YQ=="},"stringData":{"b":"b"},"type":"Opaque"}`,
+		want: `{"apiVersion":"v1","kind":"Secret","data":{"a":"<redacted>"},"stringData":{"b":"<redacted>"},"type":"Opaque"}`,
+	}, {
+		name: "data of other kinds",
+		in:   `{"apiVersion":"v1","kind":"ConfigMap","data":{"a":"a"}}`,
+		want: `{"apiVersion":"v1","kind":"ConfigMap","data":{"a":"a"}}`,
+	}, {
+		name:     "sensitive env values",
+		envNames: envNames,
+		in:       `{"kind":"Pod","spec":{"containers":[{"env":[{"name":"DB_PASSWORD","value":"p"},{"name":"MODE","value":"m"},{"name":"API_TOKEN","valueFrom":{"secretKeyRef":{"name":"s","key":"k"}}}]}]}}`,
+		want:     `{"kind":"Pod","spec":{"containers":[{"env":[{"name":"DB_PASSWORD","value":"<redacted>"},{"name":"MODE","value":"m"},{"name":"API_TOKEN","valueFrom":{"secretKeyRef":{"name":"s","key":"k"}}}]}]}}`,
+	}, {
+		name:     "env of pod templates",
+		envNames: envNames,
+		in:       `{"kind":"Deployment","spec":{"template":{"spec":{"initContainers":[{"env":[{"name":"TOKEN","value":"t"}]}]}}}}`,
+		want:     `{"kind":"Deployment","spec":{"template":{"spec":{"initContainers":[{"env":[{"name":"TOKEN","value":"<redacted>"}]}]}}}}`,
+	}, {
+		name: "env without pattern",
+		in:   `{"kind":"Pod","spec":{"containers":[{"env":[{"name":"PASSWORD","value":"p"}]}]}}`,
+		want: `{"kind":"Pod","spec":{"containers":[{"env":[{"name":"PASSWORD","value":"p"}]}]}}`,
+	}, {
+		name: "nil redactor masks secret data",
+		nil:  true,
+		in:   `{"apiVersion":"v1","kind":"Secret","data":{"a":"YQ=="},"env":[{"name":"PASSWORD","value":"p"}]}`,
+		want: `{"apiVersion":"v1","kind":"Secret","data":{"a":"<redacted>"},"env":[{"name":"PASSWORD","value":"p"}]}`,
+	}, {
+		name: "annotated fields",
+		in:   `{"metadata":{"annotations":{"mutato.kubesphere.io/redact-fields":"spec.key, spec.containers.*.args,spec.missing"}},"spec":{"key":{"nested":"k"},"containers":[{"args":["a"],"image":"i"},{"args":["b"]}]}}`,
+		want: `{"metadata":{"annotations":{"mutato.kubesphere.io/redact-fields":"spec.key, spec.containers.*.args,spec.missing"}},"spec":{"key":"<redacted>","containers":[{"args":"<redacted>","image":"i"},{"args":"<redacted>"}]}}`,
+	}, {
+		name: "annotated list index",
+		in:   `{"metadata":{"annotations":{"mutato.kubesphere.io/redact-fields":"spec.args.1"}},"spec":{"args":["a","b"]}}`,
+		want: `{"metadata":{"annotations":{"mutato.kubesphere.io/redact-fields":"spec.args.1"}},"spec":{"args":["a","<redacted>"]}}`,
+	}, {
+		name: "not an object",
+		in:   `["a"]`,
+		want: `"<redacted>"`,
+	}, {
+		name: "invalid JSON",
+		in:   `{"a":`,
+		want: `"<redacted>"`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r *Redactor
+			if !tt.nil {
+				var err error
+				if r, err = New(tt.envNames); err != nil {
+					t.Fatalf("New() = %v", err)
+				}
+			}
+			got := r.JSON([]byte(tt.in))
+			if diff := cmp.Diff(decode(t, tt.want), decode(t, string(got))); diff != "" {
+				t.Errorf("JSON() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedactorPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		want   []jsonpatch.Operation
+	}{{
+		name:   "unmasked change",
+		input:  `{"metadata":{"labels":{"a":"a"}}}`,
+		output: `{"metadata":{"labels":{"a":"b"}}}`,
+		want:   []jsonpatch.Operation{jsonpatch.NewOperation("replace", "/metadata/labels/a", "b")},
+	}, {
+		name:   "changed secret data",
+		input:  `{"apiVersion":"v1","kind":"Secret","data":{"a":"YQ=="}}`,
+		output: `{"apiVersion":"v1","kind":"Secret","data":{"a":"Yg=="}}`,
+		want:   []jsonpatch.Operation{},
+	}, {
+		name:   "added secret data",
+		input:  `{"apiVersion":"v1","kind":"Secret","data":{}}`,
+		output: `{"apiVersion":"v1","kind":"Secret","data":{"a":"YQ=="}}`,
+		want:   []jsonpatch.Operation{jsonpatch.NewOperation("add", "/data/a", Mask)},
+	}, {
+		name:   "changed sensitive env value",
+		input:  `{"spec":{"containers":[{"env":[{"name":"PASSWORD","value":"a"}]}]}}`,
+		output: `{"spec":{"containers":[{"env":[{"name":"PASSWORD","value":"b"}]}]}}`,
+		want:   []jsonpatch.Operation{},
+	}}
+	r, err := New(envNames)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Patch([]byte(tt.input), []byte(tt.output))
+			if err != nil {
+				t.Fatalf("Patch() = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Patch() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewInvalidEnvNames(t *testing.T) {
+	if _, err := New("("); err == nil {
+		t.Error("New() succeeded, want error")
+	}
+}
+
+func decode(t *testing.T, raw string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		t.Fatalf("invalid JSON %s: %v", raw, err)
+	}
+	return value
+}
@@ -0,0 +1,275 @@
+// Package report carries what mutators observe while mutating an object
+// back to the admission webhook. Mutators only receive the Mutable, so
+// reports are keyed by it for the duration of the request.
+package report
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+)
+
+var reports sync.Map
+
+// DryRun is a change a mutator did not apply because of its enforcement
+// action.
+type DryRun struct {
+	ID     types.ID
+	Action mutationsv1alpha1.EnforcementAction
+	// Input and Output are the object before and after the change, as JSON.
+	Input, Output []byte
+}
+
+// Failure is an error of a mutator whose failure policy is Ignore.
+type Failure struct {
+	ID  types.ID
+	Err error
+}
+
+// Mutation is a change applied by a mutator.
+type Mutation struct {
+	ID         types.ID
+	Generation int64
+	// Input and Output are the object before and after the change, as JSON.
+	Input, Output []byte
+	Latency       time.Duration
+}
+
+// Denial is a rejection of the object by a mutator.
+type Denial struct {
+	ID       types.ID
+	Messages []string
+}
+
+// Report collects the observations of mutators for one object.
+type Report struct {
+	ctx       context.Context
+	mu        sync.Mutex
+	dryRuns   []DryRun
+	failures  []Failure
+	warnings  []string
+	denials   []Denial
+	mutations []Mutation
+	// claims holds the mutator that injected each claimed entry.
+	claims map[string]types.ID
+	memos  map[string]interface{}
+}
+
+// Begin starts collecting a report for mutable, mutated as part of the
+// request of ctx.
+func Begin(ctx context.Context, mutable *types.Mutable) *Report {
+	r := &Report{ctx: ctx}
+	reports.Store(mutable, r)
+	return r
+}
+
+// End stops collecting the report for mutable.
+func End(mutable *types.Mutable) {
+	reports.Delete(mutable)
+}
+
+// For returns the report collected for mutable, or nil if there is none.
+// All Report methods accept a nil receiver.
+func For(mutable *types.Mutable) *Report {
+	r, ok := reports.Load(mutable)
+	if !ok {
+		return nil
+	}
+	return r.(*Report)
+}
+
+// Context returns the context of the request the object is mutated for,
+// e.g. to trace mutators.
+func (r *Report) Context() context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// AddDryRun records a change that was not applied. Mutators are evaluated
+// until the object converges, so a later dry run of the same mutator
+// replaces the earlier one.
+func (r *Report) AddDryRun(dryRun DryRun) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.dryRuns {
+		if r.dryRuns[i].ID == dryRun.ID {
+			r.dryRuns[i] = dryRun
+			return
+		}
+	}
+	r.dryRuns = append(r.dryRuns, dryRun)
+}
+
+// DryRuns returns the changes that were not applied.
+func (r *Report) DryRuns() []DryRun {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DryRun(nil), r.dryRuns...)
+}
+
+// AddFailure records an ignored failure. A later failure of the same
+// mutator replaces the earlier one.
+func (r *Report) AddFailure(failure Failure) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.failures {
+		if r.failures[i].ID == failure.ID {
+			r.failures[i] = failure
+			return
+		}
+	}
+	r.failures = append(r.failures, failure)
+}
+
+// AddWarnings records warnings for the client. Mutators are evaluated until
+// the object converges, so warnings may be added more than once.
+func (r *Report) AddWarnings(warnings ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, warnings...)
+}
+
+// Warnings returns the recorded warnings.
+func (r *Report) Warnings() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.warnings...)
+}
+
+// AddDenial records a rejection of the object. A later denial by the same
+// mutator replaces the earlier one.
+func (r *Report) AddDenial(denial Denial) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.denials {
+		if r.denials[i].ID == denial.ID {
+			r.denials[i] = denial
+			return
+		}
+	}
+	r.denials = append(r.denials, denial)
+}
+
+// Denials returns the rejections of the object.
+func (r *Report) Denials() []Denial {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Denial(nil), r.denials...)
+}
+
+// AddMutation records an applied change. Mutators are evaluated until the
+// object converges, so a later change of the same mutator replaces the
+// earlier one; the record keeps the object from before the first change and
+// the time spent on all of them.
+func (r *Report) AddMutation(mutation Mutation) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.mutations {
+		if r.mutations[i].ID == mutation.ID {
+			mutation.Input = r.mutations[i].Input
+			mutation.Latency += r.mutations[i].Latency
+			r.mutations[i] = mutation
+			return
+		}
+	}
+	r.mutations = append(r.mutations, mutation)
+}
+
+// Mutations returns the applied changes, one per mutator, in the order the
+// mutators first changed the object.
+func (r *Report) Mutations() []Mutation {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Mutation(nil), r.mutations...)
+}
+
+// Failures returns the ignored failures.
+func (r *Report) Failures() []Failure {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Failure(nil), r.failures...)
+}
+
+// Claim records that mutator id injected the entry key into the object,
+// unless another mutator claimed it first, and returns the mutator owning
+// the entry. Mutators overriding entries leave those of others alone, so
+// that two of them injecting the same entry converge instead of replacing
+// each other on every pass. Without a report every claim succeeds.
+func (r *Report) Claim(key string, id types.ID) types.ID {
+	if r == nil {
+		return id
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner, ok := r.claims[key]; ok {
+		return owner
+	}
+	if r.claims == nil {
+		r.claims = map[string]types.ID{}
+	}
+	r.claims[key] = id
+	return id
+}
+
+// Memo returns the value memoized under key, calling get on first use.
+// Mutators are evaluated until the object converges, so lookups of other
+// objects, e.g. its workspace, are memoized for the request instead of
+// being repeated on every pass. Errors are not memoized. Without a report
+// get is called every time.
+func (r *Report) Memo(key string, get func() (interface{}, error)) (interface{}, error) {
+	if r == nil {
+		return get()
+	}
+	r.mu.Lock()
+	value, ok := r.memos[key]
+	r.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+	value, err := get()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.memos == nil {
+		r.memos = map[string]interface{}{}
+	}
+	r.memos[key] = value
+	return value, nil
+}
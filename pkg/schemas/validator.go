@@ -0,0 +1,280 @@
+// Package schemas validates objects against the OpenAPI v3 schemas the API
+// server publishes through discovery.
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	"golang.org/x/sync/singleflight"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("schemas").WithValues(logging.Process, "schemas")
+
+const (
+	gvkExtension = "x-kubernetes-group-version-kind"
+	refPrefix    = "#/components/schemas/"
+)
+
+// Validator validates objects against the schema of their kind. Schemas are
+// fetched on first use. Once older than interval they are fetched again in
+// the background, so that changes to CustomResourceDefinitions are picked
+// up without blocking admission on discovery.
+type Validator struct {
+	client   openapi.Client
+	interval time.Duration
+	// fetches deduplicates concurrent fetches of the same kind.
+	fetches singleflight.Group
+
+	mu      sync.Mutex
+	paths   map[string]openapi.GroupVersion
+	fetched time.Time
+	schemas map[schema.GroupVersionKind]*cachedSchema
+}
+
+type cachedSchema struct {
+	// validator is nil if the API server publishes no schema for the kind.
+	validator apiservervalidation.SchemaValidator
+	fetched   time.Time
+}
+
+// NewValidator returns a Validator reading schemas with client.
+func NewValidator(client openapi.Client, interval time.Duration) *Validator {
+	return &Validator{
+		client:   client,
+		interval: interval,
+		schemas:  map[schema.GroupVersionKind]*cachedSchema{},
+	}
+}
+
+// Validate validates obj against the schema of its kind. Objects of kinds
+// without a published schema are not validated, nor are objects whose
+// schema could not be fetched yet.
+func (v *Validator) Validate(obj *unstructured.Unstructured) field.ErrorList {
+	if v == nil {
+		return nil
+	}
+	validator := v.validatorFor(obj.GroupVersionKind())
+	if validator == nil {
+		return nil
+	}
+	// The API server treats null fields as unset, e.g. creationTimestamp.
+	content := dropNulls(obj.UnstructuredContent()).(map[string]interface{})
+	return apiservervalidation.ValidateCustomResource(nil, content, validator)
+}
+
+// validatorFor returns the validator of gvk, fetching its schema if it was
+// never fetched. A stale validator is returned while its schema is fetched
+// again in the background.
+func (v *Validator) validatorFor(gvk schema.GroupVersionKind) apiservervalidation.SchemaValidator {
+	v.mu.Lock()
+	cached, ok := v.schemas[gvk]
+	v.mu.Unlock()
+	if !ok {
+		cached, _ = v.fetch(gvk)
+	} else if time.Since(cached.fetched) >= v.interval {
+		go func() {
+			_, _ = v.fetch(gvk)
+		}()
+	}
+	if cached == nil {
+		return nil
+	}
+	return cached.validator
+}
+
+// fetch fetches the schema of gvk and caches its validator. On error the
+// previous validator, if any, is kept, and is returned.
+func (v *Validator) fetch(gvk schema.GroupVersionKind) (*cachedSchema, error) {
+	result, err, _ := v.fetches.Do(gvk.String(), func() (interface{}, error) {
+		s, err := v.fetchSchema(gvk)
+		if err != nil {
+			return nil, err
+		}
+		cached := &cachedSchema{fetched: time.Now()}
+		if s != nil {
+			cached.validator = apiservervalidation.NewSchemaValidatorFromOpenAPI(s)
+		}
+		v.mu.Lock()
+		v.schemas[gvk] = cached
+		v.mu.Unlock()
+		return cached, nil
+	})
+	if err != nil {
+		log.Error(err, "Failed to fetch OpenAPI schema, objects are validated against the last schema fetched, if any", "gvk", gvk)
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.schemas[gvk], err
+	}
+	return result.(*cachedSchema), nil
+}
+
+// fetchSchema fetches the schema of gvk, or nil if it has none.
+func (v *Validator) fetchSchema(gvk schema.GroupVersionKind) (*spec.Schema, error) {
+	paths, err := v.groupVersions()
+	if err != nil {
+		return nil, err
+	}
+	gv, ok := paths[groupVersionPath(gvk.GroupVersion())]
+	if !ok {
+		return nil, nil
+	}
+	data, err := gv.Schema("application/json")
+	if err != nil {
+		return nil, fmt.Errorf("fetching OpenAPI schema of %s: %w", gvk.GroupVersion(), err)
+	}
+	var doc spec3.OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI schema of %s: %w", gvk.GroupVersion(), err)
+	}
+	if doc.Components == nil {
+		return nil, nil
+	}
+	return kindSchema(doc.Components.Schemas, gvk), nil
+}
+
+// groupVersions returns the OpenAPI paths of the group versions, listing
+// them again once they are older than interval.
+func (v *Validator) groupVersions() (map[string]openapi.GroupVersion, error) {
+	v.mu.Lock()
+	paths, fetched := v.paths, v.fetched
+	v.mu.Unlock()
+	if paths != nil && time.Since(fetched) < v.interval {
+		return paths, nil
+	}
+	result, err, _ := v.fetches.Do("", func() (interface{}, error) {
+		paths, err := v.client.Paths()
+		if err != nil {
+			return nil, fmt.Errorf("listing OpenAPI paths: %w", err)
+		}
+		v.mu.Lock()
+		v.paths, v.fetched = paths, time.Now()
+		v.mu.Unlock()
+		return paths, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]openapi.GroupVersion), nil
+}
+
+// groupVersionPath returns the discovery path of gv, e.g. apis/apps/v1.
+func groupVersionPath(gv schema.GroupVersion) string {
+	if gv.Group == "" {
+		return "api/" + gv.Version
+	}
+	return "apis/" + gv.Group + "/" + gv.Version
+}
+
+// kindSchema returns the schema of the component declaring gvk, with every
+// reference inlined.
+func kindSchema(components map[string]*spec.Schema, gvk schema.GroupVersionKind) *spec.Schema {
+	for name, s := range components {
+		gvks, ok := s.Extensions[gvkExtension].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range gvks {
+			declared, ok := item.(map[string]interface{})
+			if ok && declared["group"] == gvk.Group && declared["version"] == gvk.Version && declared["kind"] == gvk.Kind {
+				return inline(s, components, map[string]bool{name: true})
+			}
+		}
+	}
+	return nil
+}
+
+// inline returns a copy of s with references replaced by the components
+// they point to. The validator does not follow references itself. A
+// reference back to a component being inlined accepts any value.
+func inline(s *spec.Schema, components map[string]*spec.Schema, inlining map[string]bool) *spec.Schema {
+	if s == nil {
+		return nil
+	}
+	if ref := s.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, refPrefix)
+		target, ok := components[name]
+		if !ok || inlining[name] {
+			return &spec.Schema{}
+		}
+		inlining[name] = true
+		defer delete(inlining, name)
+		return inline(target, components, inlining)
+	}
+	// The API server wraps references in allOf to add sibling keywords such
+	// as default, which do not matter for validation and would only repeat
+	// every error of the referenced schema.
+	if len(s.AllOf) == 1 && s.AllOf[0].Ref.String() != "" {
+		return inline(&s.AllOf[0], components, inlining)
+	}
+
+	out := *s
+	if s.Properties != nil {
+		out.Properties = make(map[string]spec.Schema, len(s.Properties))
+		for key, property := range s.Properties {
+			out.Properties[key] = *inline(&property, components, inlining)
+		}
+	}
+	if s.Items != nil {
+		out.Items = &spec.SchemaOrArray{
+			Schema:  inline(s.Items.Schema, components, inlining),
+			Schemas: inlineAll(s.Items.Schemas, components, inlining),
+		}
+	}
+	if s.AdditionalProperties != nil {
+		out.AdditionalProperties = &spec.SchemaOrBool{
+			Allows: s.AdditionalProperties.Allows,
+			Schema: inline(s.AdditionalProperties.Schema, components, inlining),
+		}
+	}
+	out.AllOf = inlineAll(s.AllOf, components, inlining)
+	out.AnyOf = inlineAll(s.AnyOf, components, inlining)
+	out.OneOf = inlineAll(s.OneOf, components, inlining)
+	out.Not = inline(s.Not, components, inlining)
+	return &out
+}
+
+func inlineAll(schemas []spec.Schema, components map[string]*spec.Schema, inlining map[string]bool) []spec.Schema {
+	if schemas == nil {
+		return nil
+	}
+	out := make([]spec.Schema, len(schemas))
+	for i := range schemas {
+		out[i] = *inline(&schemas[i], components, inlining)
+	}
+	return out
+}
+
+// dropNulls returns a copy of value without null map entries.
+func dropNulls(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if item != nil {
+				out[key] = dropNulls(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = dropNulls(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
@@ -0,0 +1,151 @@
+package schemas
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/openapi"
+)
+
+// widgets is the OpenAPI document of the example.io/v1 group version, with
+// Widget referencing a Quantity component like the built-in kinds do.
+const widgets = `{
+  "openapi": "3.0.0",
+  "info": {"title": "Kubernetes", "version": "v1.30.0"},
+  "paths": {},
+  "components": {
+    "schemas": {
+      "io.example.v1.Widget": {
+        "type": "object",
+        "properties": {
+          "apiVersion": {"type": "string"},
+          "kind": {"type": "string"},
+          "metadata": {"type": "object"},
+          "spec": {
+            "type": "object",
+            "properties": {
+              "replicas": {"type": "integer", "format": "int32"},
+              "policy": {"type": "string", "enum": ["Always", "Never"]},
+              "memory": {"allOf": [{"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.api.resource.Quantity"}], "default": {}}
+            },
+            "required": ["policy"]
+          }
+        },
+        "x-kubernetes-group-version-kind": [{"group": "example.io", "kind": "Widget", "version": "v1"}]
+      },
+      "io.k8s.apimachinery.pkg.api.resource.Quantity": {
+        "type": "string"
+      }
+    }
+  }
+}`
+
+type fakeClient struct {
+	paths map[string]openapi.GroupVersion
+	err   error
+}
+
+func (c *fakeClient) Paths() (map[string]openapi.GroupVersion, error) {
+	return c.paths, c.err
+}
+
+type fakeGroupVersion struct {
+	doc string
+	err error
+}
+
+func (gv *fakeGroupVersion) Schema(string) ([]byte, error) {
+	return []byte(gv.doc), gv.err
+}
+
+func widget(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.io/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "w", "creationTimestamp": nil},
+		"spec":       spec,
+	}}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		errors int
+	}{{
+		name: "valid",
+		obj:  widget(map[string]interface{}{"replicas": int64(3), "policy": "Always", "memory": "1Gi"}),
+	}, {
+		name:   "wrong type",
+		obj:    widget(map[string]interface{}{"replicas": "3", "policy": "Always"}),
+		errors: 1,
+	}, {
+		name:   "unsupported enum value",
+		obj:    widget(map[string]interface{}{"policy": "Sometimes"}),
+		errors: 1,
+	}, {
+		name:   "missing required field",
+		obj:    widget(map[string]interface{}{"replicas": int64(3)}),
+		errors: 1,
+	}, {
+		// Quantities are only typed as strings in OpenAPI; the API server
+		// parses them when decoding, which schema validation does not do.
+		// Dynamic outputs check the quantities of container resources.
+		name: "quantity is not parsed",
+		obj:  widget(map[string]interface{}{"policy": "Never", "memory": "512000000000m"}),
+	}, {
+		name: "kind without schema",
+		obj: &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.io/v1",
+			"kind":       "Gadget",
+			"spec":       "anything",
+		}},
+	}, {
+		name: "group version without schema",
+		obj: &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "other.io/v1",
+			"kind":       "Widget",
+			"spec":       "anything",
+		}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator(&fakeClient{paths: map[string]openapi.GroupVersion{
+				"apis/example.io/v1": &fakeGroupVersion{doc: widgets},
+			}}, time.Hour)
+			errs := v.Validate(tt.obj)
+			if len(errs) != tt.errors {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errors)
+			}
+		})
+	}
+}
+
+func TestValidateFetchError(t *testing.T) {
+	invalid := widget(map[string]interface{}{"policy": "Sometimes"})
+	gv := &fakeGroupVersion{err: errors.New("unavailable")}
+	client := &fakeClient{paths: map[string]openapi.GroupVersion{"apis/example.io/v1": gv}}
+	v := NewValidator(client, time.Hour)
+
+	// Objects are not validated until a schema is fetched.
+	if errs := v.Validate(invalid); len(errs) != 0 {
+		t.Errorf("Validate() before any schema was fetched = %v, want no errors", errs)
+	}
+
+	gv.doc, gv.err = widgets, nil
+	if errs := v.Validate(invalid); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want 1 error", errs)
+	}
+
+	// A failed refresh keeps the last schema fetched.
+	client.err = errors.New("unavailable")
+	v.interval = 0
+	if _, err := v.fetch(invalid.GroupVersionKind()); err == nil {
+		t.Fatal("fetch() succeeded, want error")
+	}
+	if errs := v.Validate(invalid); len(errs) != 1 {
+		t.Errorf("Validate() after a failed refresh = %v, want 1 error", errs)
+	}
+}
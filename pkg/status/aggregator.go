@@ -0,0 +1,127 @@
+package status
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Aggregator sums up the entries the replicas write to status.byPod: the
+// last dry run of any replica becomes status.dryRun, and the Dynamic is
+// Degraded while any replica suspends its rule. Entries of pods that no
+// longer exist are removed. It runs on the leader only.
+type Aggregator struct {
+	client    client.Client
+	reader    client.Reader
+	namespace string
+	interval  time.Duration
+}
+
+// The status is written by one replica.
+var _ manager.LeaderElectionRunnable = &Aggregator{}
+
+// NewAggregator returns an Aggregator running every interval. The replicas
+// are pods in namespace, looked up with reader so that pods need not be
+// watched.
+func NewAggregator(c client.Client, reader client.Reader, namespace string, interval time.Duration) *Aggregator {
+	return &Aggregator{client: c, reader: reader, namespace: namespace, interval: interval}
+}
+
+// Start aggregates until ctx is done.
+func (a *Aggregator) Start(ctx context.Context) error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			a.aggregate(ctx)
+		}
+	}
+}
+
+func (a *Aggregator) NeedLeaderElection() bool {
+	return true
+}
+
+func (a *Aggregator) aggregate(ctx context.Context) {
+	dynamics := &mutationsv1alpha1.DynamicList{}
+	if err := a.client.List(ctx, dynamics); err != nil {
+		log.Error(err, "Failed to list Dynamic objects")
+		return
+	}
+	// pods caches whether the replicas exist for this round.
+	pods := map[string]bool{}
+	exists := func(id string) bool {
+		if exists, ok := pods[id]; ok {
+			return exists
+		}
+		err := a.reader.Get(ctx, client.ObjectKey{Namespace: a.namespace, Name: id}, &corev1.Pod{})
+		// Entries are only removed once the pod is known to be gone.
+		pods[id] = !apierrors.IsNotFound(err)
+		return pods[id]
+	}
+	for i := range dynamics.Items {
+		dynamic := &dynamics.Items[i]
+		original := dynamic.DeepCopy()
+		aggregate(dynamic, exists)
+		if equality.Semantic.DeepEqual(original.Status, dynamic.Status) {
+			continue
+		}
+		patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+		// Conflicts with replicas adding their entries are retried in the
+		// next round.
+		if err := a.client.Status().Patch(ctx, dynamic, patch); err != nil && !apierrors.IsConflict(err) {
+			log.Error(err, "Failed to aggregate status", "dynamic", dynamic.Name)
+		}
+	}
+}
+
+// aggregate updates the status of dynamic from its entries of existing
+// replicas.
+func aggregate(dynamic *mutationsv1alpha1.Dynamic, exists func(id string) bool) {
+	var byPod []mutationsv1alpha1.DynamicPodStatus
+	var suspended *mutationsv1alpha1.DynamicPodStatus
+	for i := range dynamic.Status.ByPod {
+		entry := &dynamic.Status.ByPod[i]
+		if !exists(entry.ID) {
+			continue
+		}
+		byPod = append(byPod, *entry)
+		if entry.DryRun != nil && (dynamic.Status.DryRun == nil || entry.DryRun.Time.After(dynamic.Status.DryRun.Time.Time)) {
+			dynamic.Status.DryRun = entry.DryRun.DeepCopy()
+		}
+		if entry.Suspended && suspended == nil {
+			suspended = entry
+		}
+	}
+	dynamic.Status.ByPod = byPod
+
+	switch {
+	case suspended != nil:
+		meta.SetStatusCondition(&dynamic.Status.Conditions, metav1.Condition{
+			Type:               mutationsv1alpha1.ConditionDegraded,
+			Status:             metav1.ConditionTrue,
+			Reason:             "CircuitOpen",
+			Message:            suspended.ID + ": " + suspended.Message,
+			ObservedGeneration: dynamic.Generation,
+		})
+	case meta.IsStatusConditionTrue(dynamic.Status.Conditions, mutationsv1alpha1.ConditionDegraded):
+		meta.SetStatusCondition(&dynamic.Status.Conditions, metav1.Condition{
+			Type:               mutationsv1alpha1.ConditionDegraded,
+			Status:             metav1.ConditionFalse,
+			Reason:             "CircuitClosed",
+			Message:            "The rule is active on every replica",
+			ObservedGeneration: dynamic.Generation,
+		})
+	}
+}
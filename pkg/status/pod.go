@@ -0,0 +1,169 @@
+// Package status writes what the replicas of the webhook observe to the
+// status of Dynamic objects. Every replica writes its own entry of
+// status.byPod with server-side apply; the leader aggregates the entries.
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("status").WithValues(logging.Process, "status")
+
+// PodWriter keeps the state of the Dynamic objects on this replica and
+// writes the changed ones to their status every interval. Admission
+// requests only record in memory, so they never wait for the API server.
+type PodWriter struct {
+	client   client.Client
+	pod      string
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*mutationsv1alpha1.DynamicPodStatus
+	dirty   map[string]bool
+}
+
+// PodWriter records the state of every replica.
+var _ manager.LeaderElectionRunnable = &PodWriter{}
+
+// NewPodWriter returns a PodWriter writing the entries of the named pod
+// every interval.
+func NewPodWriter(c client.Client, pod string, interval time.Duration) *PodWriter {
+	return &PodWriter{
+		client:   c,
+		pod:      pod,
+		interval: interval,
+		entries:  map[string]*mutationsv1alpha1.DynamicPodStatus{},
+		dirty:    map[string]bool{},
+	}
+}
+
+// RecordDryRun records a dry run of the named Dynamic.
+func (w *PodWriter) RecordDryRun(name string, dryRun *mutationsv1alpha1.DryRunStatus) {
+	if w == nil {
+		return
+	}
+	w.update(name, func(entry *mutationsv1alpha1.DynamicPodStatus) {
+		entry.DryRun = dryRun
+	})
+}
+
+// RecordIngestion records that the named Dynamic was reconciled at
+// generation, and whether its rule is part of the mutation system.
+func (w *PodWriter) RecordIngestion(name string, generation int64, active bool) {
+	if w == nil {
+		return
+	}
+	w.update(name, func(entry *mutationsv1alpha1.DynamicPodStatus) {
+		entry.ObservedGeneration, entry.Active = generation, active
+	})
+}
+
+// RecordSuspension records whether the circuit breaker of the named Dynamic
+// suspends its rule, and why.
+func (w *PodWriter) RecordSuspension(name string, suspended bool, message string) {
+	if w == nil {
+		return
+	}
+	w.update(name, func(entry *mutationsv1alpha1.DynamicPodStatus) {
+		entry.Suspended, entry.Message = suspended, ""
+		if suspended {
+			entry.Message = message
+		}
+	})
+}
+
+// Forget drops the state of the named Dynamic after it was deleted.
+func (w *PodWriter) Forget(name string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.entries, name)
+	delete(w.dirty, name)
+}
+
+func (w *PodWriter) update(name string, change func(entry *mutationsv1alpha1.DynamicPodStatus)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.entries[name]
+	if !ok {
+		entry = &mutationsv1alpha1.DynamicPodStatus{ID: w.pod}
+		w.entries[name] = entry
+	}
+	change(entry)
+	w.dirty[name] = true
+}
+
+// Start writes changed entries until ctx is done.
+func (w *PodWriter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection returns false as every replica serves mutations.
+func (w *PodWriter) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *PodWriter) flush(ctx context.Context) {
+	w.mu.Lock()
+	pending := make(map[string]*mutationsv1alpha1.DynamicPodStatus, len(w.dirty))
+	for name := range w.dirty {
+		pending[name] = w.entries[name].DeepCopy()
+	}
+	w.dirty = map[string]bool{}
+	w.mu.Unlock()
+
+	for name, entry := range pending {
+		err := w.apply(ctx, name, entry)
+		switch {
+		case err == nil:
+		case apierrors.IsNotFound(err):
+			w.Forget(name)
+		default:
+			log.Error(err, "Failed to update status", "dynamic", name)
+			w.mu.Lock()
+			if _, ok := w.entries[name]; ok {
+				w.dirty[name] = true
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// apply writes entry to the status of the named Dynamic. Each replica
+// applies as its own field manager, so it only ever changes its entry.
+func (w *PodWriter) apply(ctx context.Context, name string, entry *mutationsv1alpha1.DynamicPodStatus) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(entry)
+	if err != nil {
+		return err
+	}
+	dynamic := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"byPod": []interface{}{content},
+		},
+	}}
+	dynamic.SetGroupVersionKind(mutationsv1alpha1.GroupVersion.WithKind("Dynamic"))
+	dynamic.SetName(name)
+	return w.client.Status().Patch(ctx, dynamic, client.Apply, client.FieldOwner("mutato/"+w.pod), client.ForceOwnership)
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"kubesphere.io/muato/pkg/report"
+)
+
+// mutator traces the Matches and Mutate calls of the mutator it wraps as
+// children of the span of the admission request.
+type mutator struct {
+	types.Mutator
+}
+
+// Mutator returns m traced. None of the mutators of Mutato have a schema,
+// so the wrapper does not need to implement schema.MutatorWithSchema.
+func Mutator(m types.Mutator) types.Mutator {
+	if m == nil {
+		return nil
+	}
+	return &mutator{Mutator: m}
+}
+
+func (m *mutator) Matches(mutable *types.Mutable) (bool, error) {
+	_, span := Start(report.For(mutable).Context(), "Matches", MutatorAttributes(m.ID())...)
+	matches, err := m.Mutator.Matches(mutable)
+	span.SetAttributes(Matched.Bool(matches))
+	End(span, err)
+	return matches, err
+}
+
+func (m *mutator) Mutate(mutable *types.Mutable) (bool, error) {
+	_, span := Start(report.For(mutable).Context(), "Mutate", MutatorAttributes(m.ID())...)
+	mutated, err := m.Mutator.Mutate(mutable)
+	span.SetAttributes(Mutated.Bool(mutated))
+	End(span, err)
+	return mutated, err
+}
+
+func (m *mutator) HasDiff(other types.Mutator) bool {
+	if traced, ok := other.(*mutator); ok {
+		other = traced.Mutator
+	}
+	return m.Mutator.HasDiff(other)
+}
+
+func (m *mutator) DeepCopy() types.Mutator {
+	return &mutator{Mutator: m.Mutator.DeepCopy()}
+}
@@ -0,0 +1,111 @@
+// Package tracing traces admission requests with OpenTelemetry. Spans are
+// exported over OTLP once a Provider is set up; otherwise they are dropped
+// by the global no-op tracer provider.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	instrumentationName = "kubesphere.io/muato"
+	serviceName         = "mutato"
+	// shutdownTimeout bounds exporting the last spans on shutdown.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Attributes of the spans.
+const (
+	MutatorKind     = attribute.Key("mutato.mutator.kind")
+	MutatorName     = attribute.Key("mutato.mutator.name")
+	Matched         = attribute.Key("mutato.mutator.matched")
+	Mutated         = attribute.Key("mutato.mutator.mutated")
+	ObjectGroup     = attribute.Key("mutato.object.group")
+	ObjectKind      = attribute.Key("mutato.object.kind")
+	ObjectNamespace = attribute.Key("mutato.object.namespace")
+	ObjectName      = attribute.Key("mutato.object.name")
+	Operation       = attribute.Key("mutato.admission.operation")
+	RequestUID      = attribute.Key("mutato.admission.uid")
+	Result          = attribute.Key("mutato.admission.result")
+)
+
+var tracer = otel.Tracer(instrumentationName)
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// MutatorAttributes returns the attributes identifying a mutator.
+func MutatorAttributes(id types.ID) []attribute.KeyValue {
+	return []attribute.KeyValue{MutatorKind.String(id.Kind), MutatorName.String(id.Name)}
+}
+
+// Provider exports the spans of the process.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Spans are exported by every replica.
+var _ manager.LeaderElectionRunnable = &Provider{}
+
+// NewProvider returns a Provider exporting spans to the OTLP gRPC endpoint,
+// and installs it as the global tracer provider. A sampleRatio of 1 samples
+// every request not sampled by its caller already, 0 none of them.
+func NewProvider(ctx context.Context, endpoint string, insecure bool, sampleRatio float64) (*Provider, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	// The API server propagates the trace context to webhooks.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return &Provider{provider: provider}, nil
+}
+
+// Start waits until ctx is done and then exports the remaining spans.
+func (p *Provider) Start(ctx context.Context) error {
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return p.provider.Shutdown(shutdownCtx)
+}
+
+func (p *Provider) NeedLeaderElection() bool {
+	return false
+}
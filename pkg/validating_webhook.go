@@ -0,0 +1,172 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/match"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"kubesphere.io/muato/pkg/controller"
+	"kubesphere.io/muato/pkg/mutators"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Validator validates the objects of one mutation kind.
+type Validator struct {
+	NewObj func() client.Object
+	// Validate returns an error if obj can not be turned into a mutator.
+	Validate func(client.Object) error
+}
+
+// ValidatorFor returns a Validator building mutators with adder.
+func ValidatorFor(adder *controller.Adder) Validator {
+	return Validator{
+		NewObj: adder.NewMutationObj,
+		Validate: func(obj client.Object) error {
+			_, err := adder.MutatorFor(obj)
+			return err
+		},
+	}
+}
+
+// ValidatingWebhook rejects mutation objects that would fail to be
+// reconciled, so that errors surface at apply time.
+type ValidatingWebhook struct {
+	logger  logr.Logger
+	decoder runtime.Decoder
+	mapper  meta.RESTMapper
+	// Validators holds the Validator of each kind, by kind.
+	Validators map[string]Validator
+}
+
+func (v *ValidatingWebhook) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.AdmissionRequest.Operation != admissionv1.Create &&
+		req.AdmissionRequest.Operation != admissionv1.Update {
+		return admission.Allowed("Validating only on create or update")
+	}
+	validator, ok := v.Validators[req.Kind.Kind]
+	if !ok {
+		return admission.Allowed("Not a mutation kind")
+	}
+
+	obj := validator.NewObj()
+	if _, _, err := v.decoder.Decode(req.Object.Raw, nil, obj); err != nil {
+		return admission.Errored(int32(http.StatusBadRequest), err)
+	}
+	// Deleted objects only receive finalizer updates.
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return admission.Allowed("Object is being deleted")
+	}
+
+	errs, err := v.validateMatch(req.Object.Raw)
+	if err != nil {
+		return admission.Errored(int32(http.StatusBadRequest), err)
+	}
+	if err := validator.Validate(obj); err != nil {
+		errs = append(errs, specErrors(err)...)
+	}
+	if len(errs) > 0 {
+		v.logger.V(1).Info("Rejecting invalid object", "kind", req.Kind.Kind, "name", req.Name, "errors", errs)
+		return admission.Denied(fmt.Sprintf("%s %q is invalid: %v", req.Kind.Kind, req.Name, errs.ToAggregate()))
+	}
+	return admission.Allowed("")
+}
+
+// validateMatch validates spec.match of the object, if it has one.
+func (v *ValidatingWebhook) validateMatch(raw []byte) (field.ErrorList, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	content, found, err := unstructured.NestedMap(obj.Object, "spec", "match")
+	if err != nil || !found {
+		return nil, err
+	}
+	m := &match.Match{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, m); err != nil {
+		return nil, err
+	}
+	return mutators.ValidateMatch(m, v.mapper, field.NewPath("spec", "match")), nil
+}
+
+// specErrors converts a validation error into field errors. Rego errors
+// carry the line and column they occurred at.
+func specErrors(err error) field.ErrorList {
+	regoErrs := astErrors(err)
+	if regoErrs == nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec"), field.OmitValueType{}, err.Error())}
+	}
+	errs := make(field.ErrorList, 0, len(regoErrs))
+	for _, regoErr := range regoErrs {
+		message := fmt.Sprintf("%s: %s", regoErr.Code, regoErr.Message)
+		if loc := regoErr.Location; loc != nil {
+			message = fmt.Sprintf("%d:%d: %s", loc.Row, loc.Col, message)
+		}
+		errs = append(errs, field.Invalid(field.NewPath("spec", "rego"), field.OmitValueType{}, message))
+	}
+	return errs
+}
+
+// astErrors returns the Rego errors err consists of, or nil if it has other
+// causes. Parse errors are reported as rego.Errors of *ast.Error.
+func astErrors(err error) ast.Errors {
+	var astErrs ast.Errors
+	if errors.As(err, &astErrs) {
+		return astErrs
+	}
+	var regoErrs rego.Errors
+	if !errors.As(err, &regoErrs) {
+		return nil
+	}
+	for _, regoErr := range regoErrs {
+		var astErr *ast.Error
+		var errs ast.Errors
+		switch {
+		case errors.As(regoErr, &astErr):
+			astErrs = append(astErrs, astErr)
+		case errors.As(regoErr, &errs):
+			astErrs = append(astErrs, errs...)
+		default:
+			return nil
+		}
+	}
+	return astErrs
+}
+
+// SetupWebhookWithManager sets up the webhook with the manager.
+func (v *ValidatingWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	v.logger = mgr.GetLogger().WithName("mutato-validating-webhook")
+	v.decoder = serializer.NewCodecFactory(mgr.GetScheme()).UniversalDeserializer()
+	v.mapper = mgr.GetRESTMapper()
+	mgr.GetWebhookServer().Register("/validate", &webhook.Admission{Handler: v})
+	return nil
+}
@@ -18,31 +18,50 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/mutation"
 	mutationtypes "github.com/open-policy-agent/gatekeeper/v3/pkg/mutation/types"
 	"github.com/open-policy-agent/gatekeeper/v3/pkg/util"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	mutationsv1alpha1 "kubesphere.io/muato/api/mutations/v1alpha1"
+	"kubesphere.io/muato/pkg/decisionlog"
+	"kubesphere.io/muato/pkg/metrics"
+	"kubesphere.io/muato/pkg/redact"
+	"kubesphere.io/muato/pkg/report"
+	"kubesphere.io/muato/pkg/status"
+	"kubesphere.io/muato/pkg/tracing"
 	"net/http"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
 	"time"
 )
 
 const (
-	namespaceKind      = "Namespace"
-	serviceAccountName = "mutato"
+	namespaceKind = "Namespace"
+
+	// mutationsAnnotation and mutationIDAnnotation are stamped on mutated
+	// objects by the mutation system if mutation.MutationAnnotationsEnabled
+	// is set.
+	mutationsAnnotation  = "gatekeeper.sh/mutations"
+	mutationIDAnnotation = "gatekeeper.sh/mutation-id"
 )
 
 type requestResponse string
@@ -59,14 +78,34 @@ type Webhook struct {
 	reader         client.Reader
 	decoder        runtime.Decoder
 	MutationSystem *mutation.System
+	// Status records dry runs to the status entries of this replica.
+	Status *status.PodWriter
+	// MutationAnnotations keeps the annotations naming the applied mutators
+	// and the mutation ID on mutated objects.
+	MutationAnnotations bool
+	// AuditAnnotations adds the applied mutators and the mutation ID to the
+	// audit annotations of the response.
+	AuditAnnotations bool
+	// Decisions logs the applied mutations, if not nil.
+	Decisions *decisionlog.Logger
+	// Redactor masks sensitive values of objects before they are logged,
+	// recorded or exported.
+	Redactor *redact.Redactor
+	// Ready, if set, reports whether the mutators existing at startup are
+	// ingested. Until then requests are handled by NotReadyPolicy: Fail
+	// fails them, Ignore admits them unmutated.
+	Ready          func() bool
+	NotReadyPolicy mutationsv1alpha1.FailurePolicy
+	// ServiceAccount is the service account of Mutato in its namespace,
+	// whose requests are never mutated.
+	ServiceAccount string
 }
 
-var (
-	serviceaccount = fmt.Sprintf("system:serviceaccount:%s:%s", util.GetNamespace(), serviceAccountName)
-)
-
-func (r *Webhook) mutateRequest(ctx context.Context, req *admission.Request) admission.Response {
-	ns := &corev1.Namespace{}
+// namespaceOf returns the namespace of the object of req, or nil if the
+// object is cluster scoped.
+func (r *Webhook) namespaceOf(ctx context.Context, req *admission.Request) (ns *corev1.Namespace, err error) {
+	ctx, span := tracing.Start(ctx, "ResolveNamespace", tracing.ObjectNamespace.String(req.Namespace))
+	defer func() { tracing.End(span, err) }()
 
 	// if the object being mutated is a namespace itself, we use it as namespace
 	switch {
@@ -74,33 +113,42 @@ func (r *Webhook) mutateRequest(ctx context.Context, req *admission.Request) adm
 		req.Namespace = ""
 		obj, _, err := r.decoder.Decode(req.Object.Raw, nil, &corev1.Namespace{})
 		if err != nil {
-			return admission.Errored(int32(http.StatusInternalServerError), err)
+			return nil, err
 		}
-		ok := false
-		ns, ok = obj.(*corev1.Namespace)
+		ns, ok := obj.(*corev1.Namespace)
 		if !ok {
-			return admission.Errored(int32(http.StatusInternalServerError), errors.New("failed to cast namespace object"))
+			return nil, errors.New("failed to cast namespace object")
 		}
+		return ns, nil
 	case req.AdmissionRequest.Namespace != "":
+		ns = &corev1.Namespace{}
 		if err := r.client.Get(ctx, types.NamespacedName{Name: req.AdmissionRequest.Namespace}, ns); err != nil {
 			if !apierrors.IsNotFound(err) {
 				r.logger.Error(err, "error retrieving namespace", "name", req.AdmissionRequest.Namespace)
-				return admission.Errored(int32(http.StatusInternalServerError), err)
+				return nil, err
 			}
 			// bypass cached client and ask api-server directly
 			err = r.reader.Get(ctx, types.NamespacedName{Name: req.AdmissionRequest.Namespace}, ns)
 			if err != nil {
 				r.logger.Error(err, "error retrieving namespace from API server", "name", req.AdmissionRequest.Namespace)
-				return admission.Errored(int32(http.StatusInternalServerError), err)
+				return nil, err
 			}
 		}
+		return ns, nil
 	default:
-		ns = nil
+		return nil, nil
+	}
+}
+
+func (r *Webhook) mutateRequest(ctx context.Context, req *admission.Request) admission.Response {
+	ns, err := r.namespaceOf(ctx, req)
+	if err != nil {
+		return admission.Errored(int32(http.StatusInternalServerError), err)
 	}
 	obj := unstructured.Unstructured{}
-	err := obj.UnmarshalJSON(req.Object.Raw)
+	err = obj.UnmarshalJSON(req.Object.Raw)
 	if err != nil {
-		r.logger.Error(err, "failed to unmarshal", "object", string(req.Object.Raw))
+		r.logger.Error(err, "failed to unmarshal", "kind", req.Kind, "namespace", req.Namespace, "name", req.Name)
 		return admission.Errored(int32(http.StatusInternalServerError), err)
 	}
 
@@ -117,33 +165,252 @@ func (r *Webhook) mutateRequest(ctx context.Context, req *admission.Request) adm
 		Source:    mutationtypes.SourceTypeOriginal,
 	}
 
+	original := obj.GetAnnotations()
+	mutateCtx, span := tracing.Start(ctx, "MutationSystem")
+	rep := report.Begin(mutateCtx, mutable)
 	mutated, err := r.MutationSystem.Mutate(mutable)
+	report.End(mutable)
+	tracing.End(span, err)
 	if err != nil {
-		r.logger.Error(err, "failed to mutate object", "object", string(req.Object.Raw))
+		r.logger.Error(err, "failed to mutate object", "object", string(r.Redactor.JSON(req.Object.Raw)))
 		return admission.Errored(int32(http.StatusInternalServerError), err)
 	}
+	warnings := append(ruleWarnings(rep.Warnings()), r.recordDryRuns(mutable.Object, rep.DryRuns())...)
+	warnings = append(warnings, r.recordFailures(mutable.Object, rep.Failures())...)
+	if denials := rep.Denials(); len(denials) > 0 {
+		resp := admission.Denied(r.denialMessage(mutable.Object, denials))
+		resp.Warnings = warnings
+		return resp
+	}
 	if !mutated {
-		return admission.Allowed("Resource was not mutated")
+		resp := admission.Allowed("Resource was not mutated")
+		resp.Warnings = warnings
+		return resp
 	}
+	r.logDecisions(req, mutable.Object, rep.Mutations())
 
 	mutable.Object.SetNamespace(oldNS)
+	auditAnnotations := r.provenance(mutable.Object, original)
+	_, span = tracing.Start(ctx, "GeneratePatch")
 	newJSON, err := mutable.Object.MarshalJSON()
 	if err != nil {
-		r.logger.Error(err, "failed to marshal mutated object", "object", obj)
+		tracing.End(span, err)
+		r.logger.Error(err, "failed to marshal mutated object", "object", describeObject(&obj))
 		return admission.Errored(int32(http.StatusInternalServerError), err)
 	}
 	resp := admission.PatchResponseFromRaw(req.Object.Raw, newJSON)
+	tracing.End(span, nil)
+	resp.Warnings = warnings
+	resp.AuditAnnotations = auditAnnotations
 	return resp
 }
 
-func isMutatoServiceAccount(user authenticationv1.UserInfo) bool {
-	return user.Username == serviceaccount
+// provenance returns the audit annotations describing the mutation of obj.
+// Unless MutationAnnotations is set, it restores the provenance annotations
+// of obj to their original values.
+func (r *Webhook) provenance(obj *unstructured.Unstructured, original map[string]string) map[string]string {
+	annotations := obj.GetAnnotations()
+	var audit map[string]string
+	if r.AuditAnnotations && annotations[mutationIDAnnotation] != "" {
+		// The API server prefixes the keys with the name of the webhook.
+		audit = map[string]string{
+			"mutations":   annotations[mutationsAnnotation],
+			"mutation-id": annotations[mutationIDAnnotation],
+		}
+	}
+	if !r.MutationAnnotations {
+		for _, key := range []string{mutationsAnnotation, mutationIDAnnotation} {
+			if value, ok := original[key]; ok {
+				annotations[key] = value
+			} else {
+				delete(annotations, key)
+			}
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+	}
+	return audit
+}
+
+// recordDryRuns logs, counts and records the changes that were not applied
+// to obj, and returns the admission warnings for them.
+func (r *Webhook) recordDryRuns(obj *unstructured.Unstructured, dryRuns []report.DryRun) []string {
+	var warnings []string
+	for _, dryRun := range dryRuns {
+		operations, err := r.Redactor.Patch(dryRun.Input, dryRun.Output)
+		if err != nil {
+			r.logger.Error(err, "failed to create dry run patch", "mutator", dryRun.ID)
+			continue
+		}
+		if len(operations) == 0 {
+			continue
+		}
+		patch, err := json.Marshal(operations)
+		if err != nil {
+			r.logger.Error(err, "failed to marshal dry run patch", "mutator", dryRun.ID)
+			continue
+		}
+		object := describeObject(obj)
+		r.logger.Info("Dry run", "mutator", dryRun.ID, "enforcementAction", dryRun.Action, "object", object, "patch", string(patch))
+		metrics.DryRunMutations.WithLabelValues(dryRun.ID.Kind, dryRun.ID.Name, string(dryRun.Action)).Inc()
+		r.Status.RecordDryRun(dryRun.ID.Name, &mutationsv1alpha1.DryRunStatus{
+			Object: object,
+			Patch:  string(patch),
+			Time:   metav1.Now(),
+		})
+		if dryRun.Action == mutationsv1alpha1.EnforcementActionWarn {
+			warnings = append(warnings, dryRunWarning(&dryRun, operations))
+		}
+	}
+	return warnings
+}
+
+// logDecisions logs a decision for every mutation applied to obj for req.
+func (r *Webhook) logDecisions(req *admission.Request, obj *unstructured.Unstructured, mutations []report.Mutation) {
+	if !r.Decisions.Enabled() {
+		return
+	}
+	object := decisionlog.ObjectReference{
+		APIVersion: schema.GroupVersion{Group: req.Kind.Group, Version: req.Kind.Version}.String(),
+		Kind:       req.Kind.Kind,
+		Namespace:  req.Namespace,
+		Name:       req.Name,
+	}
+	if object.Name == "" {
+		object.GenerateName = obj.GetGenerateName()
+	}
+	for _, mutation := range mutations {
+		patch, err := r.Redactor.Patch(mutation.Input, mutation.Output)
+		if err != nil {
+			r.logger.Error(err, "failed to create decision patch", "mutator", mutation.ID)
+			continue
+		}
+		// Later mutators may have reverted the change.
+		if len(patch) == 0 {
+			continue
+		}
+		r.Decisions.Log(decisionlog.Decision{
+			Timestamp:  time.Now(),
+			RequestUID: string(req.UID),
+			User:       req.UserInfo.Username,
+			Operation:  string(req.Operation),
+			Object:     object,
+			Mutator: decisionlog.MutatorID{
+				Kind:       mutation.ID.Kind,
+				Name:       mutation.ID.Name,
+				Generation: mutation.Generation,
+			},
+			Patch:              patch,
+			LatencyNanoseconds: mutation.Latency.Nanoseconds(),
+		})
+	}
 }
 
-func (r *Webhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+// denialMessage logs the denials of obj and returns the reason of the
+// rejection.
+func (r *Webhook) denialMessage(obj *unstructured.Unstructured, denials []report.Denial) string {
+	var reasons []string
+	for _, denial := range denials {
+		r.logger.Info("Denying object", "mutator", denial.ID, "object", describeObject(obj), "messages", denial.Messages)
+		reasons = append(reasons, fmt.Sprintf("%s %s: %s", denial.ID.Kind, denial.ID.Name, strings.Join(denial.Messages, "; ")))
+	}
+	return "denied by " + strings.Join(reasons, ", ")
+}
+
+// recordFailures logs and counts the ignored failures of mutators and
+// returns them as admission warnings.
+func (r *Webhook) recordFailures(obj *unstructured.Unstructured, failures []report.Failure) []string {
+	var warnings []string
+	for _, failure := range failures {
+		r.logger.Info("Ignoring failure", "mutator", failure.ID, "object", describeObject(obj), "error", failure.Err.Error())
+		metrics.IgnoredFailures.WithLabelValues(failure.ID.Kind, failure.ID.Name).Inc()
+		warnings = append(warnings, fmt.Sprintf("%s %s failed and was skipped (failurePolicy: %s): %v",
+			failure.ID.Kind, failure.ID.Name, mutationsv1alpha1.FailurePolicyIgnore, failure.Err))
+	}
+	return warnings
+}
+
+const (
+	// maxRuleWarnings bounds the warnings of rules in a response.
+	maxRuleWarnings = 10
+	// maxWarningLength bounds the length of a warning of a rule. The API
+	// server may truncate longer warnings anyway.
+	maxWarningLength = 256
+)
+
+// ruleWarnings deduplicates the warnings of rules and caps their number and
+// length.
+func ruleWarnings(warnings []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, warning := range warnings {
+		if runes := []rune(warning); len(runes) > maxWarningLength {
+			warning = string(runes[:maxWarningLength-3]) + "..."
+		}
+		if warning == "" || seen[warning] {
+			continue
+		}
+		if len(result) == maxRuleWarnings {
+			return append(result, "further warnings were omitted")
+		}
+		seen[warning] = true
+		result = append(result, warning)
+	}
+	return result
+}
+
+// maxWarningOperations bounds the operations listed in a dry run warning.
+const maxWarningOperations = 5
+
+func dryRunWarning(dryRun *report.DryRun, patch []jsonpatch.Operation) string {
+	var operations []string
+	for i, op := range patch {
+		if i == maxWarningOperations {
+			operations = append(operations, fmt.Sprintf("and %d more", len(patch)-i))
+			break
+		}
+		operations = append(operations, op.Operation+" "+op.Path)
+	}
+	return fmt.Sprintf("%s %s would change the object (enforcementAction: %s): %s",
+		dryRun.ID.Kind, dryRun.ID.Name, dryRun.Action, strings.Join(operations, ", "))
+}
+
+func describeObject(obj *unstructured.Unstructured) string {
+	name := obj.GetName()
+	if name == "" {
+		name = obj.GetGenerateName() + "*"
+	}
+	if ns := obj.GetNamespace(); ns != "" {
+		name = ns + "/" + name
+	}
+	return obj.GetKind() + " " + name
+}
+
+func (r *Webhook) isMutatoServiceAccount(user authenticationv1.UserInfo) bool {
+	return user.Username == serviceaccount.MakeUsername(util.GetNamespace(), r.ServiceAccount)
+}
+
+func (r *Webhook) Handle(ctx context.Context, req admission.Request) (resp admission.Response) {
 	timeStart := time.Now()
+	ctx, span := tracing.Start(ctx, "Admission",
+		tracing.ObjectGroup.String(req.Kind.Group),
+		tracing.ObjectKind.String(req.Kind.Kind),
+		tracing.ObjectNamespace.String(req.Namespace),
+		tracing.ObjectName.String(req.Name),
+		tracing.Operation.String(string(req.Operation)),
+		tracing.RequestUID.String(string(req.UID)))
+	requestResponse := skipResponse
+	defer func() {
+		result := admissionResult(requestResponse, &resp)
+		span.SetAttributes(tracing.Result.String(result))
+		span.End()
+		metrics.AdmissionRequests.WithLabelValues(req.Kind.Kind, string(req.Operation), result).Inc()
+		metrics.AdmissionDuration.WithLabelValues(req.Kind.Kind, string(req.Operation), result).Observe(time.Since(timeStart).Seconds())
+	}()
 
-	if isMutatoServiceAccount(req.AdmissionRequest.UserInfo) {
+	if r.isMutatoServiceAccount(req.AdmissionRequest.UserInfo) {
 		return admission.Allowed("Mutato does not self-manage")
 	}
 
@@ -156,7 +423,16 @@ func (r *Webhook) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Allowed("Not mutating mutato resources")
 	}
 
-	requestResponse := unknownResponse
+	if r.Ready != nil && !r.Ready() {
+		if r.NotReadyPolicy == mutationsv1alpha1.FailurePolicyIgnore {
+			resp := admission.Allowed("Mutators are not ingested yet")
+			resp.Warnings = []string{"the object was not mutated: Mutato is starting and its mutators are not ingested yet"}
+			return resp
+		}
+		return admission.Errored(int32(http.StatusServiceUnavailable), errors.New("mutators are not ingested yet"))
+	}
+
+	requestResponse = unknownResponse
 	defer func() {
 		r.logger.V(6).Info("mutation request processed", "response", requestResponse, "duration", time.Since(timeStart))
 	}()
@@ -172,11 +448,27 @@ func (r *Webhook) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Allowed("Namespace is set to be ignored by Gatekeeper config")
 	}
 
-	resp := r.mutateRequest(ctx, &req)
+	resp = r.mutateRequest(ctx, &req)
 	requestResponse = successResponse
 	return resp
 }
 
+// admissionResult returns the result label of the admission metrics.
+func admissionResult(requestResponse requestResponse, resp *admission.Response) string {
+	switch {
+	case requestResponse == skipResponse:
+		return "skipped"
+	case !resp.Allowed && resp.Result != nil && resp.Result.Code >= http.StatusInternalServerError:
+		return "error"
+	case !resp.Allowed:
+		return "denied"
+	case len(resp.Patches) > 0:
+		return "mutated"
+	default:
+		return "allowed"
+	}
+}
+
 func (r *Webhook) skipExcludedNamespace(req *admissionv1.AdmissionRequest) (bool, error) {
 	obj := &unstructured.Unstructured{}
 	if _, _, err := r.decoder.Decode(req.Object.Raw, nil, obj); err != nil {
@@ -197,6 +489,7 @@ func (r *Webhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	r.client = mgr.GetClient()
 	r.reader = mgr.GetAPIReader()
 	r.decoder = serializer.NewCodecFactory(mgr.GetScheme()).UniversalDeserializer()
-	mgr.GetWebhookServer().Register("/mutate", &webhook.Admission{Handler: r})
+	// The handler picks up the trace context propagated by the API server.
+	mgr.GetWebhookServer().Register("/mutate", otelhttp.NewHandler(&webhook.Admission{Handler: r}, "/mutate"))
 	return nil
 }
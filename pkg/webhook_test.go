@@ -0,0 +1,69 @@
+/*
+Copyright 2025 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRuleWarnings(t *testing.T) {
+	long := strings.Repeat("é", maxWarningLength+1)
+	var many, manyWant []string
+	for i := 0; i <= maxRuleWarnings; i++ {
+		many = append(many, fmt.Sprint("warning ", i))
+	}
+	manyWant = append(append(manyWant, many[:maxRuleWarnings]...), "further warnings were omitted")
+
+	tests := []struct {
+		name     string
+		warnings []string
+		want     []string
+	}{{
+		name: "none",
+	}, {
+		name:     "duplicates and empty warnings",
+		warnings: []string{"a", "", "b", "a"},
+		want:     []string{"a", "b"},
+	}, {
+		name:     "long warning",
+		warnings: []string{long},
+		want:     []string{strings.Repeat("é", maxWarningLength-3) + "..."},
+	}, {
+		name:     "warnings equal once truncated",
+		warnings: []string{long, long + "x"},
+		want:     []string{strings.Repeat("é", maxWarningLength-3) + "..."},
+	}, {
+		name:     "too many warnings",
+		warnings: many,
+		want:     manyWant,
+	}, {
+		name:     "duplicates do not count",
+		warnings: append([]string{"warning 0"}, many[:maxRuleWarnings]...),
+		want:     many[:maxRuleWarnings],
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, ruleWarnings(tt.warnings)); diff != "" {
+				t.Errorf("ruleWarnings() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}